@@ -3,21 +3,32 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
+// exitPartialBackup is the exit code of a best-effort backup that could not
+// read every resource type.
+const exitPartialBackup = 3
+
 var (
-	backupNamespace      string
-	backupKubeconfigPath string
+	backupNamespace  string
+	backupBestEffort bool
+	backupOutput     string
 )
 
 var backupCmd = &cobra.Command{
 	Use:   "backup [namespace]",
 	Short: "Create a backup of Kubernetes resources",
-	Long:  "Create a tar.gz archive with Kubernetes manifests from the specified namespace",
-	Args:  cobra.MaximumNArgs(1),
+	Long: "Create a tar.gz archive with Kubernetes manifests from the specified namespace. " +
+		"With --best-effort, resource types that cannot be read are recorded in the archive metadata " +
+		"and the command exits with code 3 instead of failing.",
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ns := backupNamespace
 		if len(args) > 0 {
@@ -28,19 +39,84 @@ var backupCmd = &cobra.Command{
 			return fmt.Errorf("namespace is required. Use --namespace flag or provide as argument")
 		}
 
-		archivePath, err := backup.BackupNamespace(ns, backupKubeconfigPath)
+		format, err := output.ParseFormat(backupOutput)
+		if err != nil {
+			return err
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		result, err := backup.BackupNamespace(cmd.Context(), client, ns, backup.BackupOptions{BestEffort: backupBestEffort})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating backup: %v\n", err)
-			os.Exit(1)
+			printMissingPermissions(err, "kubectl-backup")
+			exit(1)
 		}
 
-		fmt.Printf("Backup created at: %s\n", archivePath)
+		if result.Partial() {
+			fmt.Fprintf(os.Stderr, "Warnings:\n")
+			for _, f := range result.Failures {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", f.Kind, f.Error)
+			}
+		}
+		if err := printBackupResult(result, format); err != nil {
+			return err
+		}
+		if result.Partial() {
+			exit(exitPartialBackup)
+		}
 		return nil
 	},
 }
 
+// printBackupResult prints the created archive in format. The wide format
+// adds the size, checksum and object counts by kind.
+func printBackupResult(result *backup.BackupResult, format output.Format) error {
+	switch format {
+	case output.JSON, output.YAML:
+		return output.Write(os.Stdout, format, result)
+	case output.Name:
+		return output.WriteNames(os.Stdout, []string{result.Path})
+	}
+
+	if result.Partial() {
+		fmt.Printf("Partial backup created at: %s\n", result.Path)
+	} else {
+		fmt.Printf("Backup created at: %s\n", result.Path)
+	}
+	if format != output.Wide {
+		return nil
+	}
+
+	kinds := make([]string, 0, len(result.ObjectsByKind))
+	for kind, n := range result.ObjectsByKind {
+		kinds = append(kinds, fmt.Sprintf("%s %d", kind, n))
+	}
+	sort.Strings(kinds)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if _, err := fmt.Fprintf(w, "Objects:\t%d (%s)\nSize:\t%d bytes\nSHA-256:\t%s\n",
+		result.Objects,
+		strings.Join(kinds, ", "),
+		result.Size,
+		result.Checksum,
+	); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(backupCmd)
 	backupCmd.Flags().StringVarP(&backupNamespace, "namespace", "n", "", "Kubernetes namespace to backup")
-	backupCmd.Flags().StringVarP(&backupKubeconfigPath, "kubeconfig", "k", "", "Path to kubeconfig file (default: auto-detect)")
+	backupCmd.Flags().BoolVar(&backupBestEffort, "best-effort", false,
+		"Skip resource types that cannot be read, record them in the archive metadata and exit with code 3")
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", string(output.Table), output.FlagUsage)
 }
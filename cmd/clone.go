@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/transform"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	cloneSelector      string
+	cloneExisting      []string
+	cloneTransformFile string
+	cloneScaleToZero   bool
+	cloneDryRun        bool
+	cloneWait          bool
+	cloneWaitTimeout   time.Duration
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone source-namespace target-namespace",
+	Short: "Copy a namespace into a new namespace of the same cluster",
+	Long: "Read the resources of a namespace and apply them to another namespace of the same cluster, " +
+		"rewriting references to the source namespace and dropping PVC bindings and Service IPs.",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, target := args[0], args[1]
+
+		filter := backup.ObjectFilter{}
+		if cloneSelector != "" {
+			selector, err := labels.Parse(cloneSelector)
+			if err != nil {
+				return fmt.Errorf("invalid selector %q: %w", cloneSelector, err)
+			}
+			filter.Selector = selector
+		}
+
+		existing, err := backup.ParseExistingPolicies(cloneExisting)
+		if err != nil {
+			return err
+		}
+
+		var transforms *transform.Config
+		if cloneTransformFile != "" {
+			transforms, err = transform.LoadFile(cloneTransformFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		report, err := backup.CloneNamespace(cmd.Context(), client, source, target, cloneScaleToZero, backup.RestoreOptions{
+			Filter:      filter,
+			Existing:    existing,
+			Transform:   transforms,
+			DryRun:      cloneDryRun,
+			Wait:        cloneWait,
+			WaitTimeout: waitTimeout(cmd, cloneWaitTimeout),
+			Progress:    os.Stdout,
+		})
+		if len(report.Results) > 0 {
+			if printErr := printRestoreReport(report); printErr != nil {
+				return printErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error cloning namespace: %v\n", err)
+			printMissingPermissions(err, "kubectl-backup-restore")
+			exit(1)
+		}
+
+		if cloneDryRun {
+			fmt.Printf("Dry run: no changes were made to the cluster\n")
+			return nil
+		}
+		fmt.Printf("Successfully cloned namespace %s to %s\n", source, target)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+	cloneCmd.Flags().StringVarP(&cloneSelector, "selector", "l", "", "Only clone objects matching this label selector (e.g. app=web)")
+	cloneCmd.Flags().StringSliceVar(&cloneExisting, "existing", []string{string(k8s.ExistingUpdate)},
+		"What to do with objects that already exist in the target namespace: skip, update, fail or recreate. "+
+			"Use Kind=policy to set it per kind")
+	cloneCmd.Flags().StringVar(&cloneTransformFile, "transform", "", "Path to a transform file with rules applied to objects before they are applied")
+	cloneCmd.Flags().BoolVar(&cloneScaleToZero, "scale-to-zero", false,
+		"Create Deployments and StatefulSets with zero replicas, keep DaemonSets off all nodes with the node selector "+
+			"kubectl-backup/scaled-to-zero=true and suspend Jobs and CronJobs")
+	cloneCmd.Flags().BoolVar(&cloneDryRun, "dry-run", false, "Validate the clone with server-side dry run without changing the cluster")
+	cloneCmd.Flags().BoolVar(&cloneWait, "wait", false, "Wait until cloned Deployments, StatefulSets, DaemonSets, Jobs and PVCs are ready")
+	cloneCmd.Flags().DurationVar(&cloneWaitTimeout, "wait-timeout", defaultWaitTimeout,
+		"Maximum time to wait with --wait. If it is not set but --timeout is, the wait lasts until the --timeout deadline")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/controller"
+	"github.com/spf13/cobra"
+)
+
+var (
+	controllerBackupDir           string
+	controllerWatchNamespace      string
+	controllerWorkers             int
+	controllerAllowCrossNamespace bool
+	controllerInstallCRDs         bool
+	controllerMetricsAddr         string
+)
+
+var controllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Reconcile Backup, Restore and BackupSchedule custom resources",
+	Long: "Run as a controller that watches Backup, Restore and BackupSchedule objects, runs the backup and restore " +
+		"engines for them and records phase, object counts, archive location and errors in their status and as Events. " +
+		"Archives are written to --backup-dir, one directory per Backup. It runs until interrupted and does not accept --timeout.",
+	Annotations: map[string]string{longRunningAnnotation: "true"},
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		ctx := cmd.Context()
+		serveMetrics(ctx, controllerMetricsAddr)
+
+		if controllerInstallCRDs {
+			if err := controller.InstallCRDs(ctx, client); err != nil {
+				fmt.Fprintf(os.Stderr, "Error installing CRDs: %v\n", err)
+				exit(1)
+			}
+		}
+
+		c := controller.New(client, controller.Options{
+			BackupDir:           controllerBackupDir,
+			Namespace:           controllerWatchNamespace,
+			Workers:             controllerWorkers,
+			AllowCrossNamespace: controllerAllowCrossNamespace,
+			Log:                 os.Stdout,
+		})
+		if err := c.Run(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running controller: %v\n", err)
+			exit(1)
+		}
+		return nil
+	},
+}
+
+var controllerCRDsCmd = &cobra.Command{
+	Use:   "crds",
+	Short: "Print the CustomResourceDefinitions used by the controller",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(controller.CRDs())
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(controllerCmd)
+	controllerCmd.AddCommand(controllerCRDsCmd)
+	controllerCmd.Flags().StringVar(&controllerBackupDir, "backup-dir", "/backups", "Directory archives are written to")
+	controllerCmd.Flags().StringVar(&controllerWatchNamespace, "watch-namespace", "", "Only reconcile objects in this namespace (default: all namespaces)")
+	controllerCmd.Flags().IntVar(&controllerWorkers, "workers", 2, "Number of objects reconciled in parallel")
+	controllerCmd.Flags().BoolVar(&controllerAllowCrossNamespace, "allow-cross-namespace", false,
+		"Allow Backups and Restores to act on namespaces other than their own")
+	controllerCmd.Flags().StringVar(&controllerMetricsAddr, "metrics-addr", defaultMetricsAddr,
+		"Address to serve Prometheus metrics on at /metrics (empty to disable)")
+	controllerCmd.Flags().BoolVar(&controllerInstallCRDs, "install-crds", false, "Create or update the CustomResourceDefinitions at startup")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/inspect"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectFilePath      string
+	inspectShow          string
+	inspectRevealSecrets bool
+	inspectOutput        string
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Show the contents of a backup archive",
+	Long:  "Print the metadata and the list of objects stored in a backup archive, or a single manifest with --show",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if inspectFilePath == "" {
+			return fmt.Errorf("backup file path is required, use --file or -f")
+		}
+
+		format, err := output.ParseFormat(inspectOutput)
+		if err != nil {
+			return err
+		}
+
+		if inspectShow != "" {
+			err = inspect.ShowObject(inspectFilePath, inspectShow, inspectRevealSecrets, format, os.Stdout)
+		} else {
+			err = inspect.InspectArchive(inspectFilePath, format, os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error inspecting backup: %v\n", err)
+			exit(1)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringVarP(&inspectFilePath, "file", "f", "", "Path to backup archive (tar.gz) to inspect (required)")
+	inspectCmd.Flags().StringVar(&inspectShow, "show", "", "Print the manifest of a single object, e.g. deployment/my-app")
+	inspectCmd.Flags().BoolVar(&inspectRevealSecrets, "reveal-secrets", false, "Show Secret values instead of redacting them")
+	inspectCmd.Flags().StringVarP(&inspectOutput, "output", "o", string(output.Table),
+		output.FlagUsage+" (--show prints YAML unless json or name is given)")
+	_ = inspectCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/install"
+	"github.com/spf13/cobra"
+)
+
+var (
+	installName               string
+	installNamespace          string
+	installBackupNamespaces   []string
+	installImage              string
+	installSchedule           string
+	installBestEffort         bool
+	installClaimName          string
+	installStorageSize        string
+	installStorageClass       string
+	installStorageAccessMode  string
+	installStorageCredentials []string
+	installApply              bool
+	installDryRun             bool
+)
+
+var installScheduleCmd = &cobra.Command{
+	Use:   "install-schedule",
+	Short: "Render or apply a CronJob that runs backups inside the cluster",
+	Long: "Render a ServiceAccount, a ClusterRole with exactly the permissions a backup needs bound by RoleBindings " +
+		"in the backed-up namespaces, a claim for the archives, an optional Secret with storage credentials and a " +
+		"CronJob per namespace running the backup command with the in-cluster configuration. " +
+		"The manifest is printed unless --apply is given.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		credentials, err := install.ParseCredentials(installStorageCredentials)
+		if err != nil {
+			return err
+		}
+
+		objs, err := install.Render(install.Options{
+			Name:               installName,
+			Namespace:          installNamespace,
+			BackupNamespaces:   installBackupNamespaces,
+			Image:              installImage,
+			Schedule:           installSchedule,
+			BestEffort:         installBestEffort,
+			ClaimName:          installClaimName,
+			StorageSize:        installStorageSize,
+			StorageClass:       installStorageClass,
+			StorageAccessMode:  installStorageAccessMode,
+			StorageCredentials: credentials,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !installApply {
+			if installDryRun {
+				return fmt.Errorf("--dry-run requires --apply")
+			}
+			manifest, err := install.Manifest(objs)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(manifest)
+			return err
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		results, err := install.Apply(cmd.Context(), client, objs, installDryRun)
+		if len(results) > 0 {
+			if printErr := printResults(results); printErr != nil {
+				return printErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error installing schedule: %v\n", err)
+			exit(1)
+		}
+
+		if installDryRun {
+			fmt.Printf("\nDry run: no changes were made to the cluster\n")
+			return nil
+		}
+		fmt.Printf("\nInstalled %d backup CronJobs in namespace %s\n", len(installBackupNamespaces), installNamespace)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(installScheduleCmd)
+	installScheduleCmd.Flags().StringVar(&installName, "name", "kubectl-backup", "Name of the ServiceAccount, RBAC objects, Secret and claim, and prefix of the CronJobs")
+	installScheduleCmd.Flags().StringVarP(&installNamespace, "namespace", "n", "kubectl-backup", "Namespace the CronJobs run in")
+	installScheduleCmd.Flags().StringSliceVar(&installBackupNamespaces, "backup-namespace", nil, "Namespace to back up, one CronJob each (required, repeatable)")
+	installScheduleCmd.Flags().StringVar(&installImage, "image", "", "Container image with kubectl-backup as its entrypoint (required)")
+	installScheduleCmd.Flags().StringVar(&installSchedule, "schedule", "0 2 * * *", "Cron expression of the backups")
+	installScheduleCmd.Flags().BoolVar(&installBestEffort, "best-effort", false, "Run the backups with --best-effort")
+	installScheduleCmd.Flags().StringVar(&installClaimName, "claim", "", "PersistentVolumeClaim the archives are written to (default: --name)")
+	installScheduleCmd.Flags().StringVar(&installStorageSize, "storage-size", "", "Also render the claim with this size (e.g. 10Gi); otherwise it must exist")
+	installScheduleCmd.Flags().StringVar(&installStorageClass, "storage-class", "", "StorageClass of the rendered claim")
+	installScheduleCmd.Flags().StringVar(&installStorageAccessMode, "storage-access-mode", "ReadWriteOnce",
+		"Access mode of the rendered claim; use ReadWriteMany if CronJobs of several namespaces may run on different nodes at once")
+	installScheduleCmd.Flags().StringArrayVar(&installStorageCredentials, "storage-credential", nil,
+		"KEY=VALUE stored in a Secret and exposed to the backups as environment variable (repeatable)")
+	installScheduleCmd.Flags().BoolVar(&installApply, "apply", false, "Apply the objects to the cluster instead of printing them")
+	installScheduleCmd.Flags().BoolVar(&installDryRun, "dry-run", false, "With --apply, validate the objects with server-side dry run without changing the cluster")
+	_ = installScheduleCmd.MarkFlagRequired("backup-namespace")
+	_ = installScheduleCmd.MarkFlagRequired("image")
+}
@@ -5,12 +5,13 @@ import (
 	"os"
 
 	"github.com/morheus9/k8s-backup-cli/internal/list"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
-	namespace      string
-	kubeconfigPath string
+	namespace  string
+	listOutput string
 )
 
 var listCmd = &cobra.Command{
@@ -28,9 +29,20 @@ var listCmd = &cobra.Command{
 			return fmt.Errorf("namespace is required. Use --namespace flag or provide as argument")
 		}
 
-		if err := list.ListResources(ns, kubeconfigPath); err != nil {
+		format, err := output.ParseFormat(listOutput)
+		if err != nil {
+			return err
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		if err := list.ListResources(cmd.Context(), client, ns, format); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		return nil
@@ -40,5 +52,5 @@ var listCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace")
-	listCmd.Flags().StringVarP(&kubeconfigPath, "kubeconfig", "k", "", "Path to kubeconfig file (default: use default kubeconfig)")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", string(output.Table), output.FlagUsage)
 }
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	logLevel  string
+	logFormat string
+)
+
+// setupLogging installs the default slog logger for --log-level and
+// --log-format. client-go logs through klog, which is routed to the same
+// logger.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid --log-level %q: must be debug, info, warn or error", logLevel)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid --log-format %q: must be text or json", logFormat)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	klog.SetSlogLogger(logger)
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/metrics"
+)
+
+// defaultMetricsAddr is the listen address of the /metrics endpoint of the
+// long-running commands.
+const defaultMetricsAddr = ":9090"
+
+var metricsTextfile string
+
+// writeMetricsTextfile writes the metrics of this run to --metrics-textfile,
+// if it is set.
+func writeMetricsTextfile() {
+	if metricsTextfile == "" {
+		return
+	}
+	if err := metrics.WriteTextfile(metricsTextfile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// exit writes the metrics textfile and exits with code. Commands use it
+// instead of os.Exit so that failed runs are reported too.
+func exit(code int) {
+	writeMetricsTextfile()
+	os.Exit(code)
+}
+
+// serveMetrics serves /metrics on addr in the background until ctx is
+// cancelled. An empty addr disables the endpoint.
+func serveMetrics(ctx context.Context, addr string) {
+	if addr == "" {
+		return
+	}
+	go func() {
+		if err := metrics.Serve(ctx, addr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error serving metrics: %v\n", err)
+			exit(1)
+		}
+	}()
+}
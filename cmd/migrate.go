@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/transform"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	migrateFromContext   string
+	migrateToContext     string
+	migrateNamespace     string
+	migrateToNamespace   string
+	migrateSelector      string
+	migrateExisting      []string
+	migrateTransformFile string
+	migrateDryRun        bool
+	migrateWait          bool
+	migrateWaitTimeout   time.Duration
+	migrateUnknownFields string
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [namespace]",
+	Short: "Copy a namespace from one cluster to another",
+	Long: "Read the resources of a namespace from the source cluster and apply them to the destination cluster " +
+		"without an intermediate backup file. Cluster-specific fields such as Service IPs and PVC bindings are removed.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ns := migrateNamespace
+		if len(args) > 0 {
+			ns = args[0]
+		}
+
+		if ns == "" {
+			return fmt.Errorf("namespace is required. Use --namespace flag or provide as argument")
+		}
+		if migrateToContext == "" {
+			return fmt.Errorf("destination context is required, use --to-context")
+		}
+
+		filter := backup.ObjectFilter{}
+		if migrateSelector != "" {
+			selector, err := labels.Parse(migrateSelector)
+			if err != nil {
+				return fmt.Errorf("invalid selector %q: %w", migrateSelector, err)
+			}
+			filter.Selector = selector
+		}
+
+		existing, err := backup.ParseExistingPolicies(migrateExisting)
+		if err != nil {
+			return err
+		}
+
+		unknownFields, err := k8s.ParseUnknownFieldPolicy(migrateUnknownFields)
+		if err != nil {
+			return err
+		}
+
+		var transforms *transform.Config
+		if migrateTransformFile != "" {
+			transforms, err = transform.LoadFile(migrateTransformFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		sourceFactory := clientFactory
+		if migrateFromContext != "" {
+			sourceFactory = clientFactory.ForContext(migrateFromContext)
+		}
+		source, err := sourceFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating source Kubernetes client: %v\n", err)
+			exit(1)
+		}
+		dest, err := clientFactory.ForContext(migrateToContext).Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating destination Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		report, err := backup.MigrateNamespace(cmd.Context(), source, dest, ns, backup.RestoreOptions{
+			Namespace:     migrateToNamespace,
+			Filter:        filter,
+			Existing:      existing,
+			Transform:     transforms,
+			DryRun:        migrateDryRun,
+			Wait:          migrateWait,
+			WaitTimeout:   waitTimeout(cmd, migrateWaitTimeout),
+			Progress:      os.Stdout,
+			UnknownFields: unknownFields,
+			Warnings:      os.Stderr,
+		})
+		if len(report.Results) > 0 {
+			if printErr := printRestoreReport(report); printErr != nil {
+				return printErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating namespace: %v\n", err)
+			printMissingPermissions(err, "kubectl-backup-restore")
+			exit(1)
+		}
+
+		if migrateDryRun {
+			fmt.Printf("Dry run: no changes were made to the cluster\n")
+			return nil
+		}
+		fmt.Printf("Successfully migrated namespace %s to context %s\n", ns, migrateToContext)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringVar(&migrateFromContext, "from-context", "", "Kubeconfig context of the source cluster (default: current context)")
+	migrateCmd.Flags().StringVar(&migrateToContext, "to-context", "", "Kubeconfig context of the destination cluster (required)")
+	migrateCmd.Flags().StringVarP(&migrateNamespace, "namespace", "n", "", "Kubernetes namespace to migrate")
+	migrateCmd.Flags().StringVar(&migrateToNamespace, "to-namespace", "", "Namespace in the destination cluster (default: same as the source namespace)")
+	migrateCmd.Flags().StringVarP(&migrateSelector, "selector", "l", "", "Only migrate objects matching this label selector (e.g. app=web)")
+	migrateCmd.Flags().StringSliceVar(&migrateExisting, "existing", []string{string(k8s.ExistingUpdate)},
+		"What to do with objects that already exist in the destination: skip, update, fail or recreate. "+
+			"Use Kind=policy to set it per kind")
+	migrateCmd.Flags().StringVar(&migrateTransformFile, "transform", "", "Path to a transform file with rules applied to objects before they are applied")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Validate the migration with server-side dry run without changing the destination cluster")
+	migrateCmd.Flags().BoolVar(&migrateWait, "wait", false, "Wait until migrated Deployments, StatefulSets, DaemonSets, Jobs and PVCs are ready")
+	migrateCmd.Flags().DurationVar(&migrateWaitTimeout, "wait-timeout", defaultWaitTimeout,
+		"Maximum time to wait with --wait. If it is not set but --timeout is, the wait lasts until the --timeout deadline")
+	migrateCmd.Flags().StringVar(&migrateUnknownFields, "unknown-fields", string(k8s.UnknownFieldsWarn),
+		"What to do with fields the destination cluster's schema does not know: warn, drop or ignore")
+}
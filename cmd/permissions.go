@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/preflight"
+)
+
+// printMissingPermissions prints the permissions listed by a
+// *preflight.PermissionError in err, followed by a Role manifest named
+// roleName that grants them. Other errors are ignored.
+func printMissingPermissions(err error, roleName string) {
+	var permErr *preflight.PermissionError
+	if !errors.As(err, &permErr) {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "The current user is missing these permissions:\n")
+	if printErr := preflight.PrintPermissions(os.Stderr, permErr.Missing); printErr != nil {
+		return
+	}
+
+	manifest, renderErr := preflight.RenderRoles(roleName, permErr.Missing)
+	if renderErr != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nGrant them with (bind the roles to your user or ServiceAccount):\n%s\n", manifest)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/preflight"
+	"github.com/morheus9/k8s-backup-cli/internal/transform"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preflightFilePath      string
+	preflightNamespace     string
+	preflightSelector      string
+	preflightNameGlob      string
+	preflightTransformFile string
+)
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight [kind/name ...]",
+	Short: "Check whether a backup can be restored into the cluster",
+	Long: "Scan a backup archive and check the target cluster for everything its objects depend on " +
+		"(namespaces, APIs and CRDs, StorageClasses, IngressClasses, PriorityClasses, RuntimeClasses, ClusterRoles) " +
+		"and for ResourceQuota headroom, without writing anything.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if preflightFilePath == "" {
+			return fmt.Errorf("backup file path is required, use --file or -f")
+		}
+
+		filter, err := buildObjectFilter(args, preflightSelector, preflightNameGlob)
+		if err != nil {
+			return err
+		}
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+
+		var transforms *transform.Config
+		if preflightTransformFile != "" {
+			transforms, err = transform.LoadFile(preflightTransformFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		objs, _, err := backup.LoadArchive(preflightFilePath, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading backup: %v\n", err)
+			exit(1)
+		}
+		if transforms != nil {
+			for _, obj := range objs {
+				if _, err := transforms.Apply(obj); err != nil {
+					return fmt.Errorf("transform: %w", err)
+				}
+			}
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		result, err := preflight.Run(cmd.Context(), client, objs, preflightNamespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running preflight checks: %v\n", err)
+			exit(1)
+		}
+		if err := preflight.Print(os.Stdout, result); err != nil {
+			return err
+		}
+		if result.Failed() {
+			exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(preflightCmd)
+	preflightCmd.Flags().StringVarP(&preflightFilePath, "file", "f", "", "Path to backup archive (tar.gz) to check (required)")
+	preflightCmd.Flags().StringVarP(&preflightNamespace, "namespace", "n", "", "Default namespace for namespaceless manifests")
+	preflightCmd.Flags().StringVarP(&preflightSelector, "selector", "l", "", "Only check objects matching this label selector (e.g. app=web)")
+	preflightCmd.Flags().StringVar(&preflightNameGlob, "name-glob", "", "Only check objects whose name matches this glob (e.g. 'api-*')")
+	preflightCmd.Flags().StringVar(&preflightTransformFile, "transform", "", "Path to a transform file applied to objects before they are checked")
+	_ = preflightCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDir         string
+	pruneNamespace   string
+	pruneKeepLast    int
+	pruneKeepDaily   int
+	pruneKeepWeekly  int
+	pruneKeepMonthly int
+	pruneDryRun      bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [namespace]",
+	Short: "Delete old backups according to a retention policy",
+	Long: "Delete old backup archives using a grandfather-father-son retention policy evaluated per namespace. " +
+		"The newest valid backup of a namespace is never deleted.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ns := pruneNamespace
+		if len(args) > 0 {
+			ns = args[0]
+		}
+
+		policy := backup.RetentionPolicy{
+			KeepLast:    pruneKeepLast,
+			KeepDaily:   pruneKeepDaily,
+			KeepWeekly:  pruneKeepWeekly,
+			KeepMonthly: pruneKeepMonthly,
+		}
+		if policy.IsEmpty() {
+			return fmt.Errorf("at least one of --keep-last, --keep-daily, --keep-weekly or --keep-monthly is required")
+		}
+
+		decisions, err := backup.PruneArchives(pruneDir, ns, policy, pruneDryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error pruning backups: %v\n", err)
+			exit(1)
+		}
+
+		if len(decisions) == 0 {
+			fmt.Printf("No backups found in %s\n", pruneDir)
+			return nil
+		}
+
+		deleteAction := "delete"
+		if pruneDryRun {
+			deleteAction = "would delete"
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		if _, err := fmt.Fprintf(w, "ACTION\tNAMESPACE\tCREATED\tARCHIVE\tREASON\n"); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+		deleted := 0
+		for _, d := range decisions {
+			action := "keep"
+			if !d.Keep {
+				action = deleteAction
+				deleted++
+			}
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				action,
+				d.Archive.Namespace,
+				d.Archive.CreatedAt.Format("2006-01-02 15:04:05"),
+				d.Archive.Path,
+				strings.Join(d.Reasons, ","),
+			); err != nil {
+				return fmt.Errorf("failed to write decision: %w", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to flush output: %w", err)
+		}
+
+		if pruneDryRun {
+			fmt.Printf("\nDry run: %d of %d backups would be deleted\n", deleted, len(decisions))
+		} else {
+			fmt.Printf("\nDeleted %d of %d backups\n", deleted, len(decisions))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVarP(&pruneDir, "dir", "d", ".", "Directory containing backup archives")
+	pruneCmd.Flags().StringVarP(&pruneNamespace, "namespace", "n", "", "Only prune backups of this namespace (default: all namespaces)")
+	pruneCmd.Flags().IntVar(&pruneKeepLast, "keep-last", 0, "Keep the N most recent backups")
+	pruneCmd.Flags().IntVar(&pruneKeepDaily, "keep-daily", 0, "Keep the most recent backup of each of the last N days")
+	pruneCmd.Flags().IntVar(&pruneKeepWeekly, "keep-weekly", 0, "Keep the most recent backup of each of the last N weeks")
+	pruneCmd.Flags().IntVar(&pruneKeepMonthly, "keep-monthly", 0, "Keep the most recent backup of each of the last N months")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show which backups would be deleted without deleting them")
+}
@@ -1,65 +1,295 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
+	"github.com/morheus9/k8s-backup-cli/internal/transform"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 var (
-	restoreFilePath       string
-	restoreNamespace      string
-	restoreKubeconfigPath string
+	restoreFilePath      string
+	restoreNamespace     string
+	restoreSelector      string
+	restoreNameGlob      string
+	restoreExisting      []string
+	restoreTransformFile string
+	restoreDryRun        bool
+	restoreRollback      bool
+	restoreSnapshotPath  string
+	restorePrune         bool
+	restoreYes           bool
+	restoreWait          bool
+	restoreWaitTimeout   time.Duration
+	restoreUnknownFields string
+	restorePreflight     bool
+	restoreOutput        string
 )
 
 var restoreCmd = &cobra.Command{
-	Use:   "restore",
+	Use:   "restore [kind/name ...]",
 	Short: "Restore Kubernetes resources from backup",
-	Long:  "Restore Kubernetes resources from a previously created backup archive",
+	Long: "Restore Kubernetes resources from a previously created backup archive. " +
+		"Pass kind/name arguments, --selector or --name-glob to restore only matching objects.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if restoreFilePath == "" {
 			return fmt.Errorf("backup file path is required, use --file or -f")
 		}
 
-		// Build rest.Config to pass into restore engine.
-		var (
-			config *rest.Config
-			err    error
-		)
+		filter, err := buildObjectFilter(args, restoreSelector, restoreNameGlob)
+		if err != nil {
+			return err
+		}
 
-		if restoreKubeconfigPath != "" {
-			config, err = clientcmd.BuildConfigFromFlags("", restoreKubeconfigPath)
-		} else {
-			loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-			configOverrides := &clientcmd.ConfigOverrides{}
-			config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-				loadingRules,
-				configOverrides,
-			).ClientConfig()
+		existing, err := backup.ParseExistingPolicies(restoreExisting)
+		if err != nil {
+			return err
+		}
+
+		unknownFields, err := k8s.ParseUnknownFieldPolicy(restoreUnknownFields)
+		if err != nil {
+			return err
 		}
+
+		format, err := output.ParseFormat(restoreOutput)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error building Kubernetes config: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		// Only the table formats share stdout with progress and prompts.
+		progress := os.Stdout
+		if !format.Tabular() {
+			progress = os.Stderr
+			if restorePrune && !restoreYes && !restoreDryRun {
+				return fmt.Errorf("--prune with -o %s requires --yes", format)
+			}
 		}
 
-		if err := backup.RestoreNamespace(restoreFilePath, restoreKubeconfigPath, restoreNamespace, config); err != nil {
+		var transforms *transform.Config
+		if restoreTransformFile != "" {
+			transforms, err = transform.LoadFile(restoreTransformFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		report, err := backup.RestoreNamespace(cmd.Context(), client, restoreFilePath, backup.RestoreOptions{
+			Namespace:         restoreNamespace,
+			Filter:            filter,
+			Existing:          existing,
+			Transform:         transforms,
+			DryRun:            restoreDryRun,
+			RollbackOnFailure: restoreRollback,
+			SnapshotPath:      restoreSnapshotPath,
+			Prune:             restorePrune,
+			ConfirmPrune:      confirmPrune,
+			Wait:              restoreWait,
+			WaitTimeout:       waitTimeout(cmd, restoreWaitTimeout),
+			Progress:          progress,
+			UnknownFields:     unknownFields,
+			Preflight:         restorePreflight,
+			Warnings:          os.Stderr,
+		})
+		printConversionWarnings(report)
+		switch {
+		case format.Structured():
+			if report.Results == nil {
+				report.Results = []backup.RestoreResult{}
+			}
+			if printErr := output.Write(os.Stdout, format, report); printErr != nil {
+				return printErr
+			}
+		case format == output.Name:
+			names := make([]string, 0, len(report.Results))
+			for _, res := range report.Results {
+				names = append(names, output.ObjectName(res.Kind, res.Name))
+			}
+			if printErr := output.WriteNames(os.Stdout, names); printErr != nil {
+				return printErr
+			}
+		case len(report.Results) > 0 || len(report.RolledBack) > 0 || len(report.Pruned) > 0 || len(report.PruneDeclined) > 0:
+			if printErr := printRestoreReport(report); printErr != nil {
+				return printErr
+			}
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error restoring backup: %v\n", err)
-			os.Exit(1)
+			printMissingPermissions(err, "kubectl-backup-restore")
+			exit(1)
 		}
 
+		if !format.Tabular() {
+			return nil
+		}
+		if restoreDryRun {
+			fmt.Printf("Dry run: no changes were made to the cluster\n")
+			return nil
+		}
 		fmt.Printf("Successfully restored resources from %s\n", restoreFilePath)
 		return nil
 	},
 }
 
+// buildObjectFilter builds the filter for kind/name arguments, a label
+// selector and a name glob.
+func buildObjectFilter(args []string, selector, nameGlob string) (backup.ObjectFilter, error) {
+	filter := backup.ObjectFilter{NameGlob: nameGlob}
+	for _, arg := range args {
+		ref, err := k8s.ParseObjectRef(arg)
+		if err != nil {
+			return filter, err
+		}
+		filter.Objects = append(filter.Objects, ref)
+	}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return filter, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		filter.Selector = parsed
+	}
+	return filter, nil
+}
+
+// printConversionWarnings warns about every object whose deprecated API
+// version was upgraded during the restore.
+func printConversionWarnings(report *backup.RestoreReport) {
+	for _, c := range report.Converted {
+		fmt.Fprintf(os.Stderr, "Warning: converted %s/%s from deprecated %s to %s\n", c.Kind, c.Name, c.From, c.To)
+	}
+}
+
+// printRestoreReport prints the action taken for every restored object and a summary line.
+func printRestoreReport(report *backup.RestoreReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "ACTION\tKIND\tNAME\tNAMESPACE\tTRANSFORMS\n"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, res := range report.Results {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			res.Action,
+			res.Kind,
+			res.Name,
+			res.Namespace,
+			strings.Join(res.Transforms, ","),
+		); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+
+	fmt.Printf("\nRestored %d objects: %d created, %d updated, %d recreated, %d skipped\n",
+		len(report.Results),
+		report.Count(k8s.ActionCreated),
+		report.Count(k8s.ActionUpdated),
+		report.Count(k8s.ActionRecreated),
+		report.Count(k8s.ActionSkipped),
+	)
+
+	if len(report.RolledBack) > 0 {
+		fmt.Printf("\nRolled back %d objects:\n", len(report.RolledBack))
+		if err := printResults(report.RolledBack); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Pruned) > 0 {
+		if restoreDryRun {
+			fmt.Printf("\nWould prune %d objects not present in the backup:\n", len(report.Pruned))
+		} else {
+			fmt.Printf("\nPruned %d objects not present in the backup:\n", len(report.Pruned))
+		}
+		if err := printResults(report.Pruned); err != nil {
+			return err
+		}
+	}
+
+	if len(report.PruneDeclined) > 0 {
+		fmt.Printf("\nSkipped pruning %d objects not present in the backup (not confirmed):\n", len(report.PruneDeclined))
+		if err := printResults(report.PruneDeclined); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printResults prints an ACTION/KIND/NAME/NAMESPACE table.
+func printResults(results []backup.RestoreResult) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "ACTION\tKIND\tNAME\tNAMESPACE\n"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, res := range results {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Action, res.Kind, res.Name, res.Namespace); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+	return nil
+}
+
+// confirmPrune lists the objects a pruning restore is about to delete and
+// asks for confirmation on stdin.
+func confirmPrune(candidates []backup.RestoreResult) (bool, error) {
+	if restoreYes {
+		return true, nil
+	}
+
+	fmt.Printf("\nThe following %d objects are not present in the backup and will be deleted:\n", len(candidates))
+	if err := printResults(candidates); err != nil {
+		return false, err
+	}
+	fmt.Printf("Delete these objects? [y/N]: ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("read answer: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 	restoreCmd.Flags().StringVarP(&restoreFilePath, "file", "f", "", "Path to backup archive (tar.gz) to restore from (required)")
 	restoreCmd.Flags().StringVarP(&restoreNamespace, "namespace", "n", "", "Default namespace for namespaceless manifests")
-	restoreCmd.Flags().StringVarP(&restoreKubeconfigPath, "kubeconfig", "k", "", "Path to kubeconfig file (default: auto-detect)")
+	restoreCmd.Flags().StringVarP(&restoreSelector, "selector", "l", "", "Only restore objects matching this label selector (e.g. app=web)")
+	restoreCmd.Flags().StringVar(&restoreNameGlob, "name-glob", "", "Only restore objects whose name matches this glob (e.g. 'api-*')")
+	restoreCmd.Flags().StringSliceVar(&restoreExisting, "existing", []string{string(k8s.ExistingUpdate)},
+		"What to do with objects that already exist: skip, update, fail or recreate. "+
+			"Use Kind=policy to set it per kind, e.g. --existing update --existing Secret=skip")
+	restoreCmd.Flags().StringVar(&restoreTransformFile, "transform", "", "Path to a transform file with rules applied to objects before they are restored")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Validate the restore with server-side dry run and preview transformed manifests without changing the cluster")
+	restoreCmd.Flags().BoolVar(&restoreRollback, "rollback-on-failure", false, "Capture the live state of every object before restoring and revert all changes if any apply fails")
+	restoreCmd.Flags().StringVar(&restoreSnapshotPath, "snapshot-file", "", "With --rollback-on-failure, also write the captured pre-restore state to this archive")
+	restoreCmd.Flags().BoolVar(&restorePrune, "prune", false, "Delete live objects of the kinds in the backup that are not present in it")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "Do not ask for confirmation before pruning")
+	restoreCmd.Flags().BoolVar(&restoreWait, "wait", false, "Wait until restored Deployments, StatefulSets, DaemonSets, Jobs and PVCs are ready")
+	restoreCmd.Flags().DurationVar(&restoreWaitTimeout, "wait-timeout", defaultWaitTimeout,
+		"Maximum time to wait with --wait. If it is not set but --timeout is, the wait lasts until the --timeout deadline")
+	restoreCmd.Flags().StringVar(&restoreUnknownFields, "unknown-fields", string(k8s.UnknownFieldsWarn),
+		"What to do with fields the target cluster's schema does not know: warn, drop or ignore")
+	restoreCmd.Flags().BoolVar(&restorePreflight, "preflight", false, "Check dependencies and quota headroom in the target cluster first and abort if a check fails")
+	restoreCmd.Flags().StringVarP(&restoreOutput, "output", "o", string(output.Table), output.FlagUsage)
 	_ = restoreCmd.MarkFlagRequired("file")
 }
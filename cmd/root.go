@@ -1,22 +1,90 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
 	"github.com/spf13/cobra"
 )
 
+// clientFactory builds the Kubernetes client for every command from the
+// kubectl connection flags registered on the root command.
+var clientFactory = k8s.NewFactory()
+
+// longRunningAnnotation marks commands that run until they are stopped and
+// therefore reject --timeout.
+const longRunningAnnotation = "kubectl-backup/long-running"
+
+var (
+	commandTimeout time.Duration
+	// cancelTimeout releases the --timeout context when the command ends.
+	cancelTimeout context.CancelFunc = func() {}
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "kubectl-backup",
 	Short: "Kubernetes Backup CLI",
 	Long:  "A CLI tool for backing up and restoring Kubernetes resources",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if commandTimeout > 0 && cmd.Annotations[longRunningAnnotation] != "" {
+			return fmt.Errorf("--timeout is not supported by %s, which runs until it is stopped", cmd.CommandPath())
+		}
+		if commandTimeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), commandTimeout)
+			cmd.SetContext(ctx)
+			cancelTimeout = cancel
+		}
+		return setupLogging()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		cancelTimeout()
+		writeMetricsTextfile()
+	},
 }
 
-// Execute executes the root command
+// defaultWaitTimeout is the readiness wait of restore, migrate and clone if
+// neither --wait-timeout nor --timeout is set.
+const defaultWaitTimeout = 5 * time.Minute
+
+// waitTimeout returns the readiness wait timeout for cmd: value if
+// --wait-timeout was set, otherwise 0 to wait until the --timeout deadline
+// if there is one, otherwise the default.
+func waitTimeout(cmd *cobra.Command, value time.Duration) time.Duration {
+	if !cmd.Flags().Changed("wait-timeout") && commandTimeout > 0 {
+		return 0
+	}
+	return value
+}
+
+func init() {
+	clientFactory.AddFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "timeout", 0,
+		"Abort the command if it has not finished after this long, e.g. 10m (0 means no limit). Not supported by schedule run and controller")
+	rootCmd.PersistentFlags().StringVar(&metricsTextfile, "metrics-textfile", "",
+		"Write Prometheus metrics of the run to this file for the node-exporter textfile collector")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn",
+		"Log level: debug (every resource type listed and object applied), info (run summaries), warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format written to stderr: text or json")
+}
+
+// Execute executes the root command. SIGINT and SIGTERM cancel the context
+// of the running command so it can stop cleanly; a second signal kills the
+// process.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleConfigPath  string
+	scheduleStateFile   string
+	scheduleMetricsAddr string
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run backups on cron schedules",
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the schedules of a config file until interrupted",
+	Long: "Run several named backup schedules (cron expression, namespaces, selector, destination, retention) " +
+		"in one long-running process. Runs of the same schedule never overlap, every run can be delayed by a random " +
+		"jitter, and the time of the last run is kept in a state file so that runs missed while the scheduler was " +
+		"down are skipped or caught up once, per schedule. It runs until interrupted and does not accept --timeout.",
+	Annotations: map[string]string{longRunningAnnotation: "true"},
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := schedule.LoadFile(scheduleConfigPath)
+		if err != nil {
+			return err
+		}
+
+		statePath := scheduleStateFile
+		if statePath == "" {
+			statePath = config.StateFile
+		}
+		if statePath == "" {
+			statePath = schedule.DefaultStateFile
+		}
+
+		client, err := clientFactory.Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Kubernetes client: %v\n", err)
+			exit(1)
+		}
+
+		ctx := cmd.Context()
+		serveMetrics(ctx, scheduleMetricsAddr)
+
+		if err := schedule.NewRunner(config, client, statePath, os.Stdout).Run(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running schedules: %v\n", err)
+			exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleRunCmd)
+	scheduleRunCmd.Flags().StringVar(&scheduleConfigPath, "config", "", "Path to the schedules file (required)")
+	scheduleRunCmd.Flags().StringVar(&scheduleStateFile, "state-file", "",
+		"Path to the file keeping the last run of every schedule (default: stateFile from the config, or "+
+			schedule.DefaultStateFile+")")
+	scheduleRunCmd.Flags().StringVar(&scheduleMetricsAddr, "metrics-addr", defaultMetricsAddr,
+		"Address to serve Prometheus metrics on at /metrics (empty to disable)")
+	_ = scheduleRunCmd.MarkFlagRequired("config")
+}
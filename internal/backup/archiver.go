@@ -3,10 +3,13 @@ package backup
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
@@ -29,34 +32,54 @@ func validatePath(path string) (string, error) {
 	return cleanPath, nil
 }
 
-// CreateArchive creates a tar.gz archive at outputPath containing the provided files.
-func CreateArchive(outputPath string, files []File) error {
+// CreateArchive creates a tar.gz archive at outputPath containing the provided
+// files and returns its hex-encoded SHA-256. The archive is written to a
+// temporary file in the same directory, synced and renamed into place, so
+// outputPath either does not exist or holds a complete archive.
+func CreateArchive(outputPath string, files []File) (string, error) {
 	// Validate output path
 	outputPath = filepath.Clean(outputPath)
+	dir := filepath.Dir(outputPath)
 
-	f, err := os.Create(outputPath)
+	// The temporary name does not end in the archive suffix, so it is never
+	// picked up as a backup. It is created readable by the owner only, as
+	// archives hold Secrets.
+	f, err := os.CreateTemp(dir, "."+filepath.Base(outputPath)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("create archive: %w", err)
+		return "", fmt.Errorf("create archive: %w", err)
 	}
-	defer func() {
-		_ = f.Close()
-	}()
+	tmpPath := f.Name()
 
-	gw := gzip.NewWriter(f)
-	defer func() {
-		_ = gw.Close()
-	}()
+	checksum, err := writeArchive(f, files)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("close archive: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, outputPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err := syncDir(dir); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
 
+// writeArchive writes files as a tar.gz stream to f, flushes every layer
+// and syncs f. It returns the SHA-256 of the bytes written.
+func writeArchive(f *os.File, files []File) (string, error) {
+	h := sha256.New()
+	gw := gzip.NewWriter(io.MultiWriter(f, h))
 	tw := tar.NewWriter(gw)
-	defer func() {
-		_ = tw.Close()
-	}()
 
 	for _, file := range files {
 		// Validate each file name to prevent path traversal
 		cleanName, err := validatePath(file.Name)
 		if err != nil {
-			return fmt.Errorf("invalid filename %s: %w", file.Name, err)
+			return "", fmt.Errorf("invalid filename %s: %w", file.Name, err)
 		}
 
 		hdr := &tar.Header{
@@ -65,13 +88,43 @@ func CreateArchive(outputPath string, files []File) error {
 			Size: int64(len(file.Data)),
 		}
 		if err := tw.WriteHeader(hdr); err != nil {
-			return fmt.Errorf("write tar header for %s: %w", cleanName, err)
+			return "", fmt.Errorf("write tar header for %s: %w", cleanName, err)
 		}
 		if _, err := tw.Write(file.Data); err != nil {
-			return fmt.Errorf("write tar data for %s: %w", cleanName, err)
+			return "", fmt.Errorf("write tar data for %s: %w", cleanName, err)
 		}
 	}
 
+	// Each Close flushes buffered data into the next layer, so the order
+	// matters and every error means the archive is incomplete.
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("finish tar stream: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return "", fmt.Errorf("finish gzip stream: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return "", fmt.Errorf("sync archive: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// syncDir flushes the directory entry of a renamed file to disk. Windows
+// cannot sync directories and persists renames on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return fmt.Errorf("open archive directory: %w", err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync archive directory: %w", err)
+	}
 	return nil
 }
 
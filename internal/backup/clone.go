@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CloneNamespace copies the objects of namespace source into namespace
+// target of the same cluster, e.g. for preview environments. References to
+// the source namespace inside the objects are rewritten, PVC bindings and
+// Service IPs are dropped. With scaleToZero, Deployments and StatefulSets
+// are created with zero replicas, DaemonSets with a node selector that
+// matches no node and Jobs and CronJobs suspended.
+func CloneNamespace(ctx context.Context, client *k8s.Client, source, target string, scaleToZero bool, opts RestoreOptions) (*RestoreReport, error) {
+	if target == "" {
+		return &RestoreReport{}, fmt.Errorf("target namespace is required")
+	}
+	if target == source {
+		return &RestoreReport{}, fmt.Errorf("target namespace must differ from the source namespace")
+	}
+	opts.Namespace = target
+
+	var prepare func(*unstructured.Unstructured)
+	if scaleToZero {
+		prepare = scaleDown
+	}
+	return copyNamespace(ctx, client, client, source, opts, prepare)
+}
+
+// scaledToZeroNodeLabel is the node selector that keeps the pods of a cloned
+// DaemonSet off every node, as DaemonSets have no replica count.
+const scaledToZeroNodeLabel = "kubectl-backup/scaled-to-zero"
+
+// scaleDown stops the workloads of obj from running.
+func scaleDown(obj *unstructured.Unstructured) {
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet":
+		_ = unstructured.SetNestedField(obj.Object, int64(0), "spec", "replicas")
+	case "DaemonSet":
+		_ = unstructured.SetNestedField(obj.Object, "true", "spec", "template", "spec", "nodeSelector", scaledToZeroNodeLabel)
+	case "Job", "CronJob":
+		_ = unstructured.SetNestedField(obj.Object, true, "spec", "suspend")
+	}
+}
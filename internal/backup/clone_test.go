@@ -0,0 +1,38 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestScaleDown(t *testing.T) {
+	tests := []struct {
+		kind  string
+		field []string
+		want  interface{}
+	}{
+		{kind: "Deployment", field: []string{"spec", "replicas"}, want: int64(0)},
+		{kind: "StatefulSet", field: []string{"spec", "replicas"}, want: int64(0)},
+		{kind: "DaemonSet", field: []string{"spec", "template", "spec", "nodeSelector", scaledToZeroNodeLabel}, want: "true"},
+		{kind: "Job", field: []string{"spec", "suspend"}, want: true},
+		{kind: "CronJob", field: []string{"spec", "suspend"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": tt.kind,
+				"spec": map[string]interface{}{"replicas": int64(3)},
+			}}
+			scaleDown(obj)
+			got, ok, err := unstructured.NestedFieldNoCopy(obj.Object, tt.field...)
+			if err != nil || !ok {
+				t.Fatalf("field %v not set: %v", tt.field, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%v = %v, want %v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,61 +3,135 @@ package backup
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/morheus9/k8s-backup-cli/internal/k8s"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/discovery/cached/memory"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
+	"github.com/morheus9/k8s-backup-cli/internal/preflight"
+	"github.com/morheus9/k8s-backup-cli/internal/transform"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
+// BackupOptions controls how a namespace is backed up.
+type BackupOptions struct {
+	// BestEffort keeps going when a resource type cannot be listed, for
+	// example because RBAC forbids it. The failed types are recorded in the
+	// archive metadata and returned in BackupResult.Failures.
+	BestEffort bool
+	// OutputDir is the directory the archive is written to. It defaults to
+	// the current working directory.
+	OutputDir string
+	// Selector restricts the backup to objects with matching labels.
+	Selector string
+}
+
+// BackupResult describes a created backup archive. Its json tags are the
+// schema of the backup command's -o json|yaml output.
+type BackupResult struct {
+	Namespace string `json:"namespace"`
+	Path      string `json:"path"`
+	// Objects is the number of objects in the archive.
+	Objects int `json:"objects"`
+	// ObjectsByKind counts the objects in the archive by kind.
+	ObjectsByKind map[string]int `json:"objectsByKind"`
+	// Size is the size of the archive in bytes.
+	Size int64 `json:"size"`
+	// Checksum is the hex-encoded SHA-256 of the archive.
+	Checksum string `json:"checksum"`
+	// Failures lists the resource types missing from a best-effort backup.
+	// The backup is partial if it is non-empty.
+	Failures []ResourceFailure `json:"failures,omitempty"`
+}
+
+// Partial reports whether some resource types are missing from the backup.
+func (r *BackupResult) Partial() bool {
+	return len(r.Failures) > 0
+}
+
 // BackupNamespace creates a tar.gz archive with Kubernetes manifests for all supported
-// resources in the given namespace. The archive is created in the current working directory.
-// It returns the full path to the created archive.
-func BackupNamespace(namespace, kubeconfigPath string) (string, error) {
-	if namespace == "" {
-		return "", fmt.Errorf("namespace is required")
+// resources in the given namespace. The archive is created in opts.OutputDir or, if that
+// is empty, the current working directory.
+// It returns the full path to the created archive and, in best-effort mode,
+// the resource types that could not be read.
+func BackupNamespace(ctx context.Context, client *k8s.Client, namespace string, opts BackupOptions) (*BackupResult, error) {
+	start := time.Now()
+	result, err := backupNamespace(ctx, client, namespace, opts)
+	observeBackup(start, namespace, result, err)
+	if err == nil {
+		slog.Info("backup completed", "namespace", namespace, "archive", result.Path, "objects", result.Objects,
+			"bytes", result.Size, "failed_resource_types", len(result.Failures), "duration", time.Since(start))
 	}
+	return result, err
+}
 
-	var (
-		client *k8s.Client
-		err    error
-	)
+func backupNamespace(ctx context.Context, client *k8s.Client, namespace string, opts BackupOptions) (*BackupResult, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("namespace is required")
+	}
 
-	if kubeconfigPath != "" {
-		client, err = k8s.NewClient(kubeconfigPath)
-	} else {
-		client, err = k8s.NewClientFromDefault()
+	if !opts.BestEffort {
+		if err := preflight.CheckPermissions(ctx, client, preflight.BackupPermissions(namespace)); err != nil {
+			return nil, fmt.Errorf("check permissions: %w", err)
+		}
 	}
+
+	manifests, exportFailures, err := client.ExportNamespace(ctx, namespace, k8s.ExportOptions{
+		BestEffort:    opts.BestEffort,
+		LabelSelector: opts.Selector,
+	})
 	if err != nil {
-		return "", fmt.Errorf("create Kubernetes client: %w", err)
+		return nil, fmt.Errorf("export manifests: %w", err)
 	}
 
-	ctx := context.Background()
-	manifests, err := client.ExportNamespaceManifests(ctx, namespace)
-	if err != nil {
-		return "", fmt.Errorf("export manifests: %w", err)
+	failures := make([]ResourceFailure, 0, len(exportFailures))
+	for _, f := range exportFailures {
+		failures = append(failures, ResourceFailure{Kind: f.Kind, Error: f.Err.Error()})
 	}
 
 	if len(manifests) == 0 {
-		return "", fmt.Errorf("no resources found in namespace %q", namespace)
+		if len(exportFailures) > 0 {
+			return nil, fmt.Errorf("no resources could be read in namespace %q: %w", namespace, exportFailures[0].Err)
+		}
+		return nil, fmt.Errorf("no resources found in namespace %q", namespace)
 	}
 
-	// Build archive path in current working directory.
-	wd, err := os.Getwd()
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("get working directory: %w", err)
+		}
+		outputDir = wd
+	}
+
+	serverVersion, err := client.Clientset.Discovery().ServerVersion()
 	if err != nil {
-		return "", fmt.Errorf("get working directory: %w", err)
+		return nil, fmt.Errorf("get server version: %w", err)
 	}
 
-	timestamp := time.Now().UTC().Format("20060102-150405")
-	filename := fmt.Sprintf("backup-%s-%s.tar.gz", namespace, timestamp)
-	outputPath := filepath.Join(wd, filename)
+	createdAt := time.Now().UTC()
+	outputPath := filepath.Join(outputDir, archiveName(namespace, createdAt))
 
-	files := make([]File, 0, len(manifests))
+	meta, err := metadataFile(Metadata{
+		Namespace:     namespace,
+		CreatedAt:     createdAt,
+		Objects:       len(manifests),
+		ServerVersion: serverVersion.GitVersion,
+		Selector:      opts.Selector,
+		Failures:      failures,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(manifests)+1)
+	files = append(files, meta)
 	for _, m := range manifests {
 		relPath := filepath.Join(namespace, m.Filename)
 		files = append(files, File{
@@ -66,65 +140,413 @@ func BackupNamespace(namespace, kubeconfigPath string) (string, error) {
 		})
 	}
 
-	if err := CreateArchive(outputPath, files); err != nil {
-		return "", fmt.Errorf("create archive: %w", err)
+	// Do not start writing the archive of an interrupted backup.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	checksum, err := CreateArchive(outputPath, files)
+	if err != nil {
+		return nil, fmt.Errorf("create archive: %w", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("stat archive: %w", err)
+	}
+	byKind := make(map[string]int)
+	for _, m := range manifests {
+		byKind[m.Kind]++
+	}
+
+	return &BackupResult{
+		Namespace:     namespace,
+		Path:          outputPath,
+		Objects:       len(manifests),
+		ObjectsByKind: byKind,
+		Size:          info.Size(),
+		Checksum:      checksum,
+		Failures:      failures,
+	}, nil
+}
+
+// RestoreOptions controls how RestoreNamespace applies an archive.
+type RestoreOptions struct {
+	// Namespace, if non-empty, is used as a default namespace for
+	// namespaceless manifests.
+	Namespace string
+	// Filter selects the objects to restore. An empty filter restores
+	// every object in the archive.
+	Filter ObjectFilter
+	// Existing decides what happens to objects that already exist.
+	Existing ExistingPolicies
+	// Transform, if set, rewrites every selected object before it is applied.
+	Transform *transform.Config
+	// DryRun sends all writes as server-side dry runs and writes the
+	// transformed manifests to Progress as a preview.
+	DryRun bool
+	// RollbackOnFailure captures the live state of every selected object
+	// before applying and reverts all changes if an apply fails.
+	RollbackOnFailure bool
+	// SnapshotPath, if set, is where the captured pre-restore state is
+	// written as an archive.
+	SnapshotPath string
+	// Prune deletes live objects of the kinds and namespaces covered by the
+	// archive that are not present in it. ConfirmPrune, if set, is asked
+	// before the first object is applied; declining it skips the prune.
+	Prune        bool
+	ConfirmPrune func(candidates []RestoreResult) (bool, error)
+	// Wait makes the restore wait until restored workloads are ready, for
+	// at most WaitTimeout, writing progress to Progress. A zero WaitTimeout
+	// waits until the deadline of the context.
+	Wait        bool
+	WaitTimeout time.Duration
+	Progress    io.Writer
+	// UnknownFields decides what happens to fields the target cluster's
+	// schema does not know. The empty value skips the check.
+	UnknownFields k8s.UnknownFieldPolicy
+	// Preflight checks the dependencies and quota headroom of the objects
+	// against the cluster before anything is written, writes the checklist
+	// to Progress and aborts if a check fails.
+	Preflight bool
+	// Warnings receives warnings as they occur, e.g. about a backup taken
+	// from a newer Kubernetes version.
+	Warnings io.Writer
+}
+
+// RestoreResult records what happened to a single object during a restore.
+type RestoreResult struct {
+	Kind      string          `json:"kind"`
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace,omitempty"`
+	Action    k8s.ApplyAction `json:"action"`
+	// Transforms lists the transform rules that changed the object.
+	Transforms []string `json:"transforms,omitempty"`
+}
+
+// RestoreReport lists the outcome of every object applied by a restore. Its
+// json tags are the schema of the restore command's -o json|yaml output.
+type RestoreReport struct {
+	Results []RestoreResult `json:"results"`
+	// RolledBack lists the objects reverted after a failed apply.
+	RolledBack []RestoreResult `json:"rolledBack,omitempty"`
+	// Pruned lists the live objects deleted (or, in a dry run, that would be
+	// deleted) because they are not in the archive.
+	Pruned []RestoreResult `json:"pruned,omitempty"`
+	// PruneDeclined lists the objects that were not pruned because the
+	// confirmation was declined.
+	PruneDeclined []RestoreResult `json:"pruneDeclined,omitempty"`
+	// Converted lists the objects upgraded from a deprecated API version.
+	Converted []Conversion `json:"converted,omitempty"`
+}
+
+// Conversion records an object whose deprecated apiVersion was upgraded
+// before it was applied.
+type Conversion struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// Count returns the number of objects for which action was taken.
+func (r *RestoreReport) Count(action k8s.ApplyAction) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Action == action {
+			n++
+		}
 	}
+	return n
+}
 
-	return outputPath, nil
+// logRestore logs the outcome of a successful restore that started at start.
+// Errors are left to the caller, which returns or prints them.
+func logRestore(start time.Time, report *RestoreReport, opts RestoreOptions, err error) {
+	if err != nil {
+		return
+	}
+	slog.Info("restore completed", "namespace", opts.Namespace, "objects", len(report.Results),
+		"created", report.Count(k8s.ActionCreated), "updated", report.Count(k8s.ActionUpdated),
+		"recreated", report.Count(k8s.ActionRecreated), "skipped", report.Count(k8s.ActionSkipped),
+		"pruned", len(report.Pruned), "dry_run", opts.DryRun, "duration", time.Since(start))
 }
 
 // RestoreNamespace restores resources from a tar.gz archive into the cluster.
-// If namespaceOverride is non-empty, it is used as a default namespace for
-// namespaceless manifests.
-func RestoreNamespace(archivePath, kubeconfigPath, namespaceOverride string, cfg *rest.Config) error {
+// Only the objects selected by opts.Filter are applied. The returned report
+// lists every object handled so far, also when an error is returned.
+func RestoreNamespace(ctx context.Context, client *k8s.Client, archivePath string, opts RestoreOptions) (*RestoreReport, error) {
+	start := time.Now()
+	report := &RestoreReport{}
+	err := restoreNamespace(ctx, client, archivePath, opts, report)
+	observeRestore(start, report, opts.DryRun, err)
+	logRestore(start, report, opts, err)
+	return report, err
+}
+
+func restoreNamespace(ctx context.Context, client *k8s.Client, archivePath string, opts RestoreOptions, report *RestoreReport) error {
 	if archivePath == "" {
 		return fmt.Errorf("archive path is required")
 	}
+	if err := opts.Filter.Validate(); err != nil {
+		return err
+	}
+	if opts.Prune && !opts.Filter.IsEmpty() {
+		return fmt.Errorf("prune cannot be combined with object filters")
+	}
+
+	objs, meta, err := LoadArchive(archivePath, opts.Filter)
+	if err != nil {
+		return err
+	}
+	if meta != nil && meta.ServerVersion != "" && opts.Warnings != nil {
+		if err := warnVersionSkew(client, meta.ServerVersion, opts); err != nil {
+			return err
+		}
+	}
+
+	return restoreObjects(ctx, client, objs, meta, opts, report)
+}
 
-	var (
-		client *k8s.Client
-		err    error
-	)
+// RestoreObjects applies objs to the cluster with the same pipeline as
+// RestoreNamespace: transforms, optional snapshot and rollback, pruning and
+// waiting. opts.Filter is not used; objs are applied as given, in
+// dependency order.
+func RestoreObjects(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, opts RestoreOptions) (*RestoreReport, error) {
+	start := time.Now()
+	report := &RestoreReport{}
+	err := restoreObjects(ctx, client, objs, nil, opts, report)
+	observeRestore(start, report, opts.DryRun, err)
+	logRestore(start, report, opts, err)
+	return report, err
+}
 
-	if kubeconfigPath != "" {
-		client, err = k8s.NewClient(kubeconfigPath)
-	} else {
-		client, err = k8s.NewClientFromDefault()
+// restoreObjects applies objs with the restore pipeline. meta describes the
+// archive objs were loaded from, or is nil if they were not; a prune only
+// considers live objects within the archive's selector.
+func restoreObjects(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, meta *Metadata, opts RestoreOptions, report *RestoreReport) error {
+	var selector labels.Selector
+	if opts.Prune {
+		var err error
+		if selector, err = pruneSelector(meta); err != nil {
+			return err
+		}
 	}
-	if err != nil {
-		return fmt.Errorf("create Kubernetes client: %w", err)
+
+	for _, obj := range objs {
+		from, converted, err := k8s.ConvertDeprecated(obj)
+		if err != nil {
+			return err
+		}
+		if converted {
+			report.Converted = append(report.Converted, Conversion{
+				Kind:      obj.GetKind(),
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+				From:      from,
+				To:        obj.GetAPIVersion(),
+			})
+		}
+	}
+	k8s.SortForApply(objs)
+
+	var err error
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = io.Discard
+	}
+
+	transforms := make([][]string, len(objs))
+	if opts.Transform != nil {
+		for i, obj := range objs {
+			transforms[i], err = opts.Transform.Apply(obj)
+			if err != nil {
+				return fmt.Errorf("transform: %w", err)
+			}
+			if opts.DryRun && len(transforms[i]) > 0 {
+				if err := previewTransform(progress, obj, transforms[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	schemaCheck := opts.UnknownFields == k8s.UnknownFieldsWarn || opts.UnknownFields == k8s.UnknownFieldsDrop
+	perms := preflight.RestorePermissions(client, objs, opts.Namespace, preflight.RestoreNeeds{
+		Delete:      opts.RollbackOnFailure || opts.Prune || opts.Existing.Uses(k8s.ExistingRecreate),
+		List:        opts.Prune,
+		Wait:        opts.Wait && !opts.DryRun,
+		Preflight:   opts.Preflight,
+		SchemaCheck: schemaCheck,
+	})
+	if err := preflight.CheckPermissions(ctx, client, perms); err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+
+	if schemaCheck {
+		if err := checkUnknownFields(client, objs, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.Preflight {
+		result, err := preflight.Run(ctx, client, objs, opts.Namespace)
+		if err != nil {
+			return fmt.Errorf("preflight: %w", err)
+		}
+		if err := preflight.Print(progress, result); err != nil {
+			return err
+		}
+		if result.Failed() {
+			return fmt.Errorf("preflight checks failed, nothing was restored")
+		}
+	}
+
+	// Confirm the prune before the cluster is changed.
+	var orphans []*unstructured.Unstructured
+	if opts.Prune {
+		orphans, err = planPrune(ctx, client, objs, selector, opts, report)
+		if err != nil {
+			return err
+		}
+	}
+
+	var snap *snapshot
+	if opts.RollbackOnFailure && !opts.DryRun {
+		snap, err = captureSnapshot(ctx, client, objs, opts.Namespace)
+		if err != nil {
+			return err
+		}
+		if opts.SnapshotPath != "" {
+			if err := snap.writeArchive(opts.SnapshotPath, opts.Namespace); err != nil {
+				return fmt.Errorf("write pre-restore snapshot: %w", err)
+			}
+		}
+	}
+
+	// Indices of the objects written so far, for rollback.
+	var touched []int
+	for i, obj := range objs {
+		action, err := client.ApplyObject(ctx, obj, k8s.ApplyOptions{
+			NamespaceFallback: opts.Namespace,
+			Existing:          opts.Existing.For(obj.GetKind()),
+			DryRun:            opts.DryRun,
+		})
+		if err != nil {
+			applyErr := fmt.Errorf("apply %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+			if snap == nil {
+				return applyErr
+			}
+			// A failed recreate may already have deleted the object, so it is reverted too.
+			return rollbackRestore(ctx, client, snap, append(touched, i), opts, report, applyErr)
+		}
+		if action != k8s.ActionSkipped {
+			touched = append(touched, i)
+		}
+		report.Results = append(report.Results, RestoreResult{
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+			Namespace:  obj.GetNamespace(),
+			Action:     action,
+			Transforms: transforms[i],
+		})
+	}
+
+	if err := pruneOrphans(ctx, client, orphans, report); err != nil {
+		return err
+	}
+
+	if opts.Wait && !opts.DryRun {
+		keys := make([]k8s.ObjectKey, 0, len(report.Results))
+		for _, res := range report.Results {
+			keys = append(keys, k8s.ObjectKey{Kind: res.Kind, Namespace: res.Namespace, Name: res.Name})
+		}
+		if err := client.WaitForReady(ctx, keys, opts.WaitTimeout, progress); err != nil {
+			return fmt.Errorf("wait for restored objects: %w", err)
+		}
 	}
 
+	return nil
+}
+
+// rollbackRestore reverts the touched objects after applyErr and returns the
+// error to report. If any object cannot be reverted, the pre-restore snapshot is
+// saved to disk so it can be restored manually. The rollback also runs when
+// the restore was interrupted or ran out of time.
+func rollbackRestore(ctx context.Context, client *k8s.Client, snap *snapshot, touched []int, opts RestoreOptions, report *RestoreReport, applyErr error) error {
+	rolledBack, err := snap.rollback(context.WithoutCancel(ctx), client, touched)
+	report.RolledBack = rolledBack
+	if err == nil {
+		return fmt.Errorf("%w (rolled back %d objects)", applyErr, len(rolledBack))
+	}
+
+	snapshotPath := opts.SnapshotPath
+	if snapshotPath == "" {
+		snapshotPath = fmt.Sprintf("pre-restore-%s-%s%s", opts.Namespace, time.Now().UTC().Format(timestampLayout), archiveSuffix)
+		if writeErr := snap.writeArchive(snapshotPath, opts.Namespace); writeErr != nil {
+			return fmt.Errorf("%w; rolled back %d of %d objects, rollback failed: %v; saving pre-restore snapshot failed: %v",
+				applyErr, len(rolledBack), len(touched), err, writeErr)
+		}
+	}
+	return fmt.Errorf("%w; rolled back %d of %d objects, rollback failed: %v; pre-restore state saved to %s",
+		applyErr, len(rolledBack), len(touched), err, snapshotPath)
+}
+
+// LoadArchive extracts the archive at archivePath and decodes the manifests
+// selected by filter. The returned metadata is nil for archives created
+// before metadata was recorded.
+func LoadArchive(archivePath string, filter ObjectFilter) ([]*unstructured.Unstructured, *Metadata, error) {
 	files, err := ExtractArchive(archivePath)
 	if err != nil {
-		return fmt.Errorf("extract archive: %w", err)
+		return nil, nil, fmt.Errorf("extract archive: %w", err)
+	}
+	meta, files, err := SplitMetadata(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read archive metadata: %w", err)
 	}
 
 	if len(files) == 0 {
-		return fmt.Errorf("archive %q is empty", archivePath)
+		return nil, nil, fmt.Errorf("archive %q is empty", archivePath)
 	}
 
-	// Prepare dynamic client and RESTMapper based on provided REST config.
-	if cfg == nil {
-		return fmt.Errorf("REST config is required")
+	objs, err := decodeFiles(files)
+	if err != nil {
+		return nil, nil, err
 	}
-	disco, err := discovery.NewDiscoveryClientForConfig(cfg)
+	objs, err = filter.Apply(objs)
 	if err != nil {
-		return fmt.Errorf("create discovery client: %w", err)
+		return nil, nil, err
+	}
+	if len(objs) == 0 {
+		return nil, nil, fmt.Errorf("no objects in archive %q match the given filters", archivePath)
 	}
-	cachedDisco := memory.NewMemCacheClient(disco)
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDisco)
-	dyn, err := dynamic.NewForConfig(cfg)
+
+	return objs, meta, nil
+}
+
+// previewTransform writes the transformed manifest of obj to w.
+func previewTransform(w io.Writer, obj *unstructured.Unstructured, transforms []string) error {
+	data, err := yaml.Marshal(obj.Object)
 	if err != nil {
-		return fmt.Errorf("create dynamic client: %w", err)
+		return fmt.Errorf("marshal %s/%s: %w", obj.GetKind(), obj.GetName(), err)
 	}
+	if _, err := fmt.Fprintf(w, "# %s/%s transformed by %s\n%s---\n",
+		obj.GetKind(), obj.GetName(), strings.Join(transforms, ", "), data); err != nil {
+		return fmt.Errorf("write preview: %w", err)
+	}
+	return nil
+}
 
-	ctx := context.Background()
+// decodeFiles decodes the manifests extracted from an archive.
+func decodeFiles(files []File) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0, len(files))
 	for _, f := range files {
-		if err := client.ApplyYAML(ctx, mapper, dyn, namespaceOverride, f.Data); err != nil {
-			return fmt.Errorf("apply manifest %s: %w", f.Name, err)
+		obj, err := k8s.DecodeManifest(f.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode manifest %s: %w", f.Name, err)
 		}
+		objs = append(objs, obj)
 	}
-
-	return nil
+	return objs, nil
 }
@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ObjectFilter selects a subset of the objects in an archive. Objects must
+// match every filter that is set; an empty filter matches everything.
+type ObjectFilter struct {
+	// Objects restricts the selection to the referenced objects.
+	Objects []k8s.ObjectRef
+	// Selector restricts the selection to objects whose labels match.
+	Selector labels.Selector
+	// NameGlob restricts the selection to objects whose name matches the pattern.
+	NameGlob string
+}
+
+// IsEmpty reports whether the filter selects every object.
+func (f ObjectFilter) IsEmpty() bool {
+	return len(f.Objects) == 0 && (f.Selector == nil || f.Selector.Empty()) && f.NameGlob == ""
+}
+
+// Validate checks that the filter is well-formed.
+func (f ObjectFilter) Validate() error {
+	if f.NameGlob != "" {
+		if _, err := path.Match(f.NameGlob, ""); err != nil {
+			return fmt.Errorf("invalid name glob %q: %w", f.NameGlob, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether obj is selected by the filter.
+func (f ObjectFilter) Matches(obj *unstructured.Unstructured) bool {
+	if len(f.Objects) > 0 {
+		found := false
+		for _, ref := range f.Objects {
+			if ref.Matches(obj) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.Selector != nil && !f.Selector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+
+	if f.NameGlob != "" {
+		if ok, _ := path.Match(f.NameGlob, obj.GetName()); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns the objects selected by the filter. It fails if a referenced
+// object is not present in objs at all.
+func (f ObjectFilter) Apply(objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var missing []string
+	for _, ref := range f.Objects {
+		found := false
+		for _, obj := range objs {
+			if ref.Matches(obj) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, ref.String())
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("not present in archive: %s", strings.Join(missing, ", "))
+	}
+
+	selected := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if f.Matches(obj) {
+			selected = append(selected, obj)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MetadataFile is the name of the archive entry describing the backup.
+const MetadataFile = "metadata.json"
+
+// Metadata describes a backup archive. It is stored as MetadataFile at the
+// root of the archive, next to the namespace directory with the manifests.
+type Metadata struct {
+	Namespace string    `json:"namespace"`
+	CreatedAt time.Time `json:"createdAt"`
+	Objects   int       `json:"objects"`
+	// ServerVersion is the Kubernetes version of the cluster the manifests
+	// were read from, e.g. v1.31.2.
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// Selector is the label selector the backup was restricted to, if any.
+	Selector string `json:"selector,omitempty"`
+	// Failures lists the resource types a best-effort backup could not
+	// read. An archive with failures is partial.
+	Failures []ResourceFailure `json:"failures,omitempty"`
+}
+
+// ResourceFailure records a resource type missing from a partial backup.
+type ResourceFailure struct {
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+// metadataFile encodes m as an archive entry.
+func metadataFile(m Metadata) (File, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return File{}, fmt.Errorf("marshal metadata: %w", err)
+	}
+	return File{Name: MetadataFile, Data: data}, nil
+}
+
+// SplitMetadata separates the metadata entry from the manifests of an
+// extracted archive. Archives created before metadata was recorded have no
+// such entry, in which case the returned metadata is nil.
+func SplitMetadata(files []File) (*Metadata, []File, error) {
+	var (
+		meta      *Metadata
+		manifests = make([]File, 0, len(files))
+	)
+
+	for _, f := range files {
+		if f.Name != MetadataFile {
+			manifests = append(manifests, f)
+			continue
+		}
+		var m Metadata
+		if err := json.Unmarshal(f.Data, &m); err != nil {
+			return nil, nil, fmt.Errorf("decode %s: %w", MetadataFile, err)
+		}
+		meta = &m
+	}
+
+	return meta, manifests, nil
+}
@@ -0,0 +1,36 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/metrics"
+)
+
+// observeBackup records the metrics of a backup of namespace that started
+// at start.
+func observeBackup(start time.Time, namespace string, result *BackupResult, err error) {
+	b := metrics.Backup{
+		Namespace: namespace,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+	if result != nil {
+		b.Objects = result.ObjectsByKind
+		b.ArchiveBytes = result.Size
+		b.FailedResourceTypes = len(result.Failures)
+	}
+	metrics.ObserveBackup(b)
+}
+
+// observeRestore records the metrics of a restore that started at start.
+func observeRestore(start time.Time, report *RestoreReport, dryRun bool, err error) {
+	objects := make([]metrics.RestoredObject, 0, len(report.Results))
+	for _, res := range report.Results {
+		objects = append(objects, metrics.RestoredObject{
+			Namespace: res.Namespace,
+			Kind:      res.Kind,
+			Action:    string(res.Action),
+		})
+	}
+	metrics.ObserveRestore(time.Since(start), objects, dryRun, err)
+}
@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/preflight"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MigrateNamespace copies the objects of namespace from the source cluster
+// into the destination cluster through the restore pipeline, without an
+// intermediate archive. Objects are sanitized for the new cluster and placed
+// in opts.Namespace, or in namespace if it is empty. The destination
+// namespace is created if it does not exist.
+func MigrateNamespace(ctx context.Context, source, dest *k8s.Client, namespace string, opts RestoreOptions) (*RestoreReport, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = namespace
+	}
+	return copyNamespace(ctx, source, dest, namespace, opts, nil)
+}
+
+// copyNamespace exports namespace from source, sanitizes the objects, maps
+// them to opts.Namespace, calls prepare (if set) on each and applies them to
+// dest with the restore pipeline.
+func copyNamespace(ctx context.Context, source, dest *k8s.Client, namespace string, opts RestoreOptions, prepare func(*unstructured.Unstructured)) (*RestoreReport, error) {
+	start := time.Now()
+	report := &RestoreReport{}
+	err := copyObjects(ctx, source, dest, namespace, opts, prepare, report)
+	observeRestore(start, report, opts.DryRun, err)
+	logRestore(start, report, opts, err)
+	return report, err
+}
+
+func copyObjects(ctx context.Context, source, dest *k8s.Client, namespace string, opts RestoreOptions, prepare func(*unstructured.Unstructured), report *RestoreReport) error {
+	if namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if err := opts.Filter.Validate(); err != nil {
+		return err
+	}
+	if opts.Prune && !opts.Filter.IsEmpty() {
+		return fmt.Errorf("prune cannot be combined with object filters")
+	}
+
+	if source != dest && opts.Warnings != nil {
+		info, err := source.Clientset.Discovery().ServerVersion()
+		if err != nil {
+			return fmt.Errorf("get source server version: %w", err)
+		}
+		if err := warnVersionSkew(dest, info.GitVersion, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := preflight.CheckPermissions(ctx, source, preflight.BackupPermissions(namespace)); err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+
+	objs, err := exportObjects(ctx, source, namespace)
+	if err != nil {
+		return err
+	}
+	objs, err = opts.Filter.Apply(objs)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return fmt.Errorf("no objects to copy in namespace %q", namespace)
+	}
+	for _, obj := range objs {
+		k8s.Sanitize(obj)
+		k8s.MapNamespace(obj, namespace, opts.Namespace)
+		if prepare != nil {
+			prepare(obj)
+		}
+	}
+
+	created, err := dest.EnsureNamespace(ctx, opts.Namespace, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	if created {
+		if opts.DryRun {
+			// Server-side dry runs of namespaced objects need the namespace to exist.
+			return fmt.Errorf("namespace %q does not exist; a dry run cannot validate objects in it", opts.Namespace)
+		}
+		report.Results = append(report.Results, RestoreResult{
+			Kind:   "Namespace",
+			Name:   opts.Namespace,
+			Action: k8s.ActionCreated,
+		})
+	}
+
+	return restoreObjects(ctx, dest, objs, nil, opts, report)
+}
+
+// exportObjects reads the supported objects of namespace from the cluster.
+// ServiceAccount token Secrets are skipped, they are generated by the
+// destination cluster, and so are objects managed by a controller, such as
+// the Jobs of a CronJob: their owner creates them again when it needs them.
+func exportObjects(ctx context.Context, client *k8s.Client, namespace string) ([]*unstructured.Unstructured, error) {
+	manifests, err := client.ExportNamespaceManifests(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("export manifests: %w", err)
+	}
+
+	objs := make([]*unstructured.Unstructured, 0, len(manifests))
+	for _, m := range manifests {
+		obj, err := k8s.DecodeManifest(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("decode manifest %s: %w", m.Filename, err)
+		}
+		if t, _, _ := unstructured.NestedString(obj.Object, "type"); t == serviceAccountTokenType {
+			continue
+		}
+		if hasController(obj) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// hasController reports whether obj has an owner reference to its managing
+// controller.
+func hasController(obj *unstructured.Unstructured) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package backup
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHasController(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		refs []metav1.OwnerReference
+		want bool
+	}{
+		{name: "no owner"},
+		{name: "job of a cronjob", refs: []metav1.OwnerReference{{Kind: "CronJob", Name: "report", Controller: &yes}}, want: true},
+		{name: "plain owner", refs: []metav1.OwnerReference{{Kind: "ConfigMap", Name: "base"}}},
+		{name: "owner that is not the controller", refs: []metav1.OwnerReference{{Kind: "ConfigMap", Name: "base", Controller: &no}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := configMap("settings", nil)
+			obj.SetOwnerReferences(tt.refs)
+			if got := hasController(obj); got != tt.want {
+				t.Errorf("hasController = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// serviceAccountTokenType is the type of Secrets created for ServiceAccounts
+// by the token controller.
+const serviceAccountTokenType = "kubernetes.io/service-account-token"
+
+// kindScope is a kind in a namespace covered by a restore.
+type kindScope struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+}
+
+// pruneSelector returns the live objects a prune may consider: those matching
+// the selector the archive was restricted to, or all objects if meta is nil
+// or the backup had no selector.
+func pruneSelector(meta *Metadata) (labels.Selector, error) {
+	if meta == nil || meta.Selector == "" {
+		return labels.Everything(), nil
+	}
+	selector, err := labels.Parse(meta.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("prune cannot be limited to the archive selector %q: %w", meta.Selector, err)
+	}
+	return selector, nil
+}
+
+// findOrphans returns the live objects matching selector that are not in
+// objs, limited to the kinds and namespaces that objs cover. Cluster-scoped
+// kinds, system objects and objects owned by another object are never
+// returned.
+func findOrphans(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	inArchive := make(map[kindScope]map[string]bool)
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			continue
+		}
+		scope := kindScope{gvk: obj.GroupVersionKind(), namespace: obj.GetNamespace()}
+		if inArchive[scope] == nil {
+			inArchive[scope] = make(map[string]bool)
+		}
+		inArchive[scope][obj.GetName()] = true
+	}
+
+	scopes := make([]kindScope, 0, len(inArchive))
+	for scope := range inArchive {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].namespace != scopes[j].namespace {
+			return scopes[i].namespace < scopes[j].namespace
+		}
+		return scopes[i].gvk.Kind < scopes[j].gvk.Kind
+	})
+
+	var orphans []*unstructured.Unstructured
+	for _, scope := range scopes {
+		live, err := client.ListObjects(ctx, scope.gvk, scope.namespace)
+		if err != nil {
+			return nil, err
+		}
+		for i := range live {
+			obj := &live[i]
+			if inArchive[scope][obj.GetName()] || !selector.Matches(labels.Set(obj.GetLabels())) || !isPrunable(obj) {
+				continue
+			}
+			// List returns items without kind; keep it for deletion and reporting.
+			obj.SetGroupVersionKind(scope.gvk)
+			orphans = append(orphans, obj)
+		}
+	}
+
+	return orphans, nil
+}
+
+// isPrunable reports whether a live object may be deleted by a pruning restore.
+func isPrunable(obj *unstructured.Unstructured) bool {
+	if k8s.IsSystemObject(obj.GetKind(), obj.GetNamespace(), obj.GetName()) {
+		return false
+	}
+	// Objects managed by a controller (ReplicaSets, Jobs of CronJobs, ...) are
+	// cleaned up through their owner.
+	if len(obj.GetOwnerReferences()) > 0 {
+		return false
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		return false
+	}
+	if t, _, _ := unstructured.NestedString(obj.Object, "type"); t == serviceAccountTokenType {
+		return false
+	}
+	return true
+}
+
+// planPrune finds the live objects matching selector that are not present
+// in objs and asks
+// opts.ConfirmPrune whether to delete them. It returns the objects to delete
+// after the apply. With opts.DryRun they are only reported in
+// report.Pruned; if the prune is declined, they are reported in
+// report.PruneDeclined and nothing is deleted.
+func planPrune(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, selector labels.Selector, opts RestoreOptions, report *RestoreReport) ([]*unstructured.Unstructured, error) {
+	orphans, err := findOrphans(ctx, client, objs, selector)
+	if err != nil {
+		return nil, fmt.Errorf("find objects to prune: %w", err)
+	}
+	if len(orphans) == 0 {
+		return nil, nil
+	}
+
+	candidates := make([]RestoreResult, 0, len(orphans))
+	for _, obj := range orphans {
+		candidates = append(candidates, pruneResult(obj, k8s.ActionDeleted))
+	}
+
+	if opts.DryRun {
+		report.Pruned = candidates
+		return nil, nil
+	}
+
+	if opts.ConfirmPrune != nil {
+		ok, err := opts.ConfirmPrune(candidates)
+		if err != nil {
+			return nil, fmt.Errorf("confirm prune: %w", err)
+		}
+		if !ok {
+			for _, obj := range orphans {
+				report.PruneDeclined = append(report.PruneDeclined, pruneResult(obj, k8s.ActionSkipped))
+			}
+			return nil, nil
+		}
+	}
+
+	return orphans, nil
+}
+
+// pruneOrphans deletes the orphans returned by planPrune.
+func pruneOrphans(ctx context.Context, client *k8s.Client, orphans []*unstructured.Unstructured, report *RestoreReport) error {
+	for _, obj := range orphans {
+		if err := client.DeleteObject(ctx, obj); err != nil {
+			return fmt.Errorf("prune: %w", err)
+		}
+		report.Pruned = append(report.Pruned, pruneResult(obj, k8s.ActionDeleted))
+	}
+	return nil
+}
+
+func pruneResult(obj *unstructured.Unstructured, action k8s.ApplyAction) RestoreResult {
+	return RestoreResult{
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Action:    action,
+	}
+}
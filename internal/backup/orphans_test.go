@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s/k8stest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+func configMap(name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("shop")
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+// writeTestArchive writes objs to an archive with the given backup selector
+// and returns its path.
+func writeTestArchive(t *testing.T, selector string, objs ...*unstructured.Unstructured) string {
+	t.Helper()
+	meta, err := metadataFile(Metadata{Namespace: "shop", CreatedAt: time.Now(), Objects: len(objs), Selector: selector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []File{meta}
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, File{
+			Name: filepath.Join(obj.GetNamespace(), k8s.ManifestFilename(obj.GetKind(), obj.GetName())),
+			Data: data,
+		})
+	}
+	path := filepath.Join(t.TempDir(), "backup-shop.tar.gz")
+	if _, err := CreateArchive(path, files); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindOrphansHonorsArchiveSelector(t *testing.T) {
+	config := map[string]string{"tier": "config"}
+	web := map[string]string{"tier": "web"}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+	}{
+		{name: "no selector", selector: "", want: []string{"old-config", "web-settings"}},
+		{name: "selector", selector: "tier=config", want: []string{"old-config"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestArchive(t, tt.selector, configMap("app-config", config))
+			objs, meta, err := LoadArchive(path, ObjectFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, _ := k8stest.NewClient(
+				runtime.Object(configMap("app-config", config)),
+				configMap("old-config", config),
+				configMap("web-settings", web),
+			)
+			selector, err := pruneSelector(meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			orphans, err := findOrphans(context.Background(), client, objs, selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, obj := range orphans {
+				got = append(got, obj.GetName())
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("orphans = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneSelectorRejectsInvalidSelector(t *testing.T) {
+	if _, err := pruneSelector(&Metadata{Selector: "tier in (config"}); err == nil {
+		t.Error("expected an error for an unparsable archive selector")
+	}
+}
@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+)
+
+// ExistingPolicies selects the policy for objects that already exist in the
+// cluster, optionally overridden per kind.
+type ExistingPolicies struct {
+	Default k8s.ExistingPolicy
+	ByKind  map[string]k8s.ExistingPolicy
+}
+
+// ParseExistingPolicies parses values of the form "policy" or "Kind=policy",
+// e.g. ["update", "Secret=skip"]. A bare policy sets the default, which is
+// "update" if not given.
+func ParseExistingPolicies(values []string) (ExistingPolicies, error) {
+	policies := ExistingPolicies{
+		Default: k8s.ExistingUpdate,
+		ByKind:  make(map[string]k8s.ExistingPolicy),
+	}
+
+	for _, value := range values {
+		kind, name, hasKind := strings.Cut(value, "=")
+		if !hasKind {
+			name = kind
+		}
+
+		policy, err := k8s.ParseExistingPolicy(strings.TrimSpace(name))
+		if err != nil {
+			return ExistingPolicies{}, err
+		}
+
+		if !hasKind {
+			policies.Default = policy
+			continue
+		}
+
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			return ExistingPolicies{}, fmt.Errorf("invalid existing object policy %q, expected Kind=policy", value)
+		}
+		policies.ByKind[k8s.NormalizeKind(kind)] = policy
+	}
+
+	return policies, nil
+}
+
+// For returns the policy that applies to objects of kind.
+func (p ExistingPolicies) For(kind string) k8s.ExistingPolicy {
+	for k, policy := range p.ByKind {
+		if strings.EqualFold(k, kind) {
+			return policy
+		}
+	}
+	if p.Default == "" {
+		return k8s.ExistingUpdate
+	}
+	return p.Default
+}
+
+// Uses reports whether policy applies to any kind.
+func (p ExistingPolicies) Uses(policy k8s.ExistingPolicy) bool {
+	if p.For("") == policy {
+		return true
+	}
+	for _, kindPolicy := range p.ByKind {
+		if kindPolicy == policy {
+			return true
+		}
+	}
+	return false
+}
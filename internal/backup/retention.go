@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	archivePrefix   = "backup-"
+	archiveSuffix   = ".tar.gz"
+	timestampLayout = "20060102-150405"
+)
+
+// RetentionPolicy describes which backups to keep for each namespace using a
+// grandfather-father-son scheme. A zero value for a field disables that rule.
+type RetentionPolicy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// IsEmpty reports whether the policy has no keep rules at all.
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0 && p.KeepMonthly <= 0
+}
+
+// Archive describes a backup archive found in a backup directory.
+type Archive struct {
+	Path      string
+	Namespace string
+	CreatedAt time.Time
+}
+
+// PruneDecision records whether an archive is kept and why.
+type PruneDecision struct {
+	Archive Archive
+	Keep    bool
+	Reasons []string
+}
+
+// archiveName returns the file name used for a backup of namespace taken at t.
+func archiveName(namespace string, t time.Time) string {
+	return fmt.Sprintf("%s%s-%s%s", archivePrefix, namespace, t.UTC().Format(timestampLayout), archiveSuffix)
+}
+
+// ParseArchiveName extracts the namespace and creation time from a file name
+// produced by BackupNamespace (backup-<namespace>-<timestamp>.tar.gz).
+func ParseArchiveName(name string) (string, time.Time, bool) {
+	if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+		return "", time.Time{}, false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix)
+
+	// The timestamp has a fixed width and is separated from the namespace by a dash.
+	if len(rest) < len(timestampLayout)+2 || rest[len(rest)-len(timestampLayout)-1] != '-' {
+		return "", time.Time{}, false
+	}
+	namespace := rest[:len(rest)-len(timestampLayout)-1]
+	createdAt, err := time.Parse(timestampLayout, rest[len(rest)-len(timestampLayout):])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return namespace, createdAt, true
+}
+
+// FindArchives returns all backup archives in dir, newest first.
+func FindArchives(dir string) ([]Archive, error) {
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, fmt.Errorf("read backup directory: %w", err)
+	}
+
+	var archives []Archive
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		namespace, createdAt, ok := ParseArchiveName(entry.Name())
+		if !ok {
+			continue
+		}
+		archives = append(archives, Archive{
+			Path:      filepath.Join(dir, entry.Name()),
+			Namespace: namespace,
+			CreatedAt: createdAt,
+		})
+	}
+
+	sortNewestFirst(archives)
+	return archives, nil
+}
+
+func sortNewestFirst(archives []Archive) {
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].CreatedAt.After(archives[j].CreatedAt)
+	})
+}
+
+// ApplyRetention evaluates policy against archives separately for every
+// namespace. The newest valid archive of a namespace is always kept, even if
+// the policy would otherwise remove it, so pruning can never leave a
+// namespace without a restorable backup.
+func ApplyRetention(archives []Archive, policy RetentionPolicy) []PruneDecision {
+	byNamespace := make(map[string][]Archive)
+	var namespaces []string
+	for _, a := range archives {
+		if _, ok := byNamespace[a.Namespace]; !ok {
+			namespaces = append(namespaces, a.Namespace)
+		}
+		byNamespace[a.Namespace] = append(byNamespace[a.Namespace], a)
+	}
+	sort.Strings(namespaces)
+
+	var decisions []PruneDecision
+	for _, ns := range namespaces {
+		decisions = append(decisions, applyNamespaceRetention(byNamespace[ns], policy)...)
+	}
+	return decisions
+}
+
+func applyNamespaceRetention(archives []Archive, policy RetentionPolicy) []PruneDecision {
+	sortNewestFirst(archives)
+
+	decisions := make([]PruneDecision, len(archives))
+	for i, a := range archives {
+		decisions[i].Archive = a
+	}
+
+	keepBuckets := func(limit int, rule string, bucket func(time.Time) string) {
+		if limit <= 0 {
+			return
+		}
+		last := ""
+		kept := 0
+		for i := range decisions {
+			if kept >= limit {
+				return
+			}
+			key := bucket(decisions[i].Archive.CreatedAt)
+			if key == last {
+				continue
+			}
+			last = key
+			kept++
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, rule)
+		}
+	}
+
+	keepBuckets(policy.KeepLast, "last", func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	})
+	keepBuckets(policy.KeepDaily, "daily", func(t time.Time) string {
+		return t.UTC().Format("2006-01-02")
+	})
+	keepBuckets(policy.KeepWeekly, "weekly", func(t time.Time) string {
+		year, week := t.UTC().ISOWeek()
+		return fmt.Sprintf("%d-%02d", year, week)
+	})
+	keepBuckets(policy.KeepMonthly, "monthly", func(t time.Time) string {
+		return t.UTC().Format("2006-01")
+	})
+
+	// Always keep the newest archive that can actually be restored.
+	for i := range decisions {
+		if isValidArchive(decisions[i].Archive.Path) {
+			if !decisions[i].Keep {
+				decisions[i].Keep = true
+				decisions[i].Reasons = append(decisions[i].Reasons, "newest valid backup")
+			}
+			break
+		}
+	}
+
+	return decisions
+}
+
+// isValidArchive reports whether path is a readable archive with at least one file.
+func isValidArchive(path string) bool {
+	files, err := ExtractArchive(path)
+	return err == nil && len(files) > 0
+}
+
+// PruneArchives applies policy to the backups in dir and deletes the archives
+// that are not kept. If namespace is non-empty, only backups of that namespace
+// are considered. With dryRun set nothing is deleted.
+func PruneArchives(dir, namespace string, policy RetentionPolicy, dryRun bool) ([]PruneDecision, error) {
+	if policy.IsEmpty() {
+		return nil, fmt.Errorf("at least one keep rule is required")
+	}
+
+	archives, err := FindArchives(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace != "" {
+		filtered := archives[:0]
+		for _, a := range archives {
+			if a.Namespace == namespace {
+				filtered = append(filtered, a)
+			}
+		}
+		archives = filtered
+	}
+
+	decisions := ApplyRetention(archives, policy)
+	if dryRun {
+		return decisions, nil
+	}
+
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		if err := os.Remove(d.Archive.Path); err != nil {
+			return decisions, fmt.Errorf("delete archive %s: %w", d.Archive.Path, err)
+		}
+	}
+
+	return decisions, nil
+}
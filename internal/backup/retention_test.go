@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testArchive describes an archive for a retention test. Invalid archives
+// are written as files that cannot be extracted.
+type testArchive struct {
+	namespace string
+	createdAt string
+	invalid   bool
+}
+
+// writeRetentionArchives writes archives to a temporary directory and
+// returns them as FindArchives would.
+func writeRetentionArchives(t *testing.T, archives []testArchive) []Archive {
+	t.Helper()
+	dir := t.TempDir()
+	out := make([]Archive, 0, len(archives))
+	for _, a := range archives {
+		createdAt, err := time.Parse(time.RFC3339, a.createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, archiveName(a.namespace, createdAt))
+		if a.invalid {
+			if err := os.WriteFile(path, []byte("not a gzip archive"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := CreateArchive(path, []File{{Name: "metadata.yaml", Data: []byte("namespace: " + a.namespace)}}); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, Archive{Path: path, Namespace: a.namespace, CreatedAt: createdAt})
+	}
+	return out
+}
+
+func TestApplyRetention(t *testing.T) {
+	tests := []struct {
+		name     string
+		archives []testArchive
+		policy   RetentionPolicy
+		// want maps the creation time of every kept archive to its reasons.
+		want map[string][]string
+	}{
+		{
+			name: "keep last",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-01T10:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-02T10:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-03T10:00:00Z"},
+			},
+			policy: RetentionPolicy{KeepLast: 2},
+			want: map[string][]string{
+				"2025-12-03T10:00:00Z": {"last"},
+				"2025-12-02T10:00:00Z": {"last"},
+			},
+		},
+		{
+			name: "same day keeps the newest archive of each day",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-02T01:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-02T23:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-03T08:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-03T20:00:00Z"},
+			},
+			policy: RetentionPolicy{KeepDaily: 2},
+			want: map[string][]string{
+				"2025-12-03T20:00:00Z": {"daily"},
+				"2025-12-02T23:00:00Z": {"daily"},
+			},
+		},
+		{
+			name: "same day counts in UTC",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-03T00:30:00+02:00"},
+				{namespace: "shop", createdAt: "2025-12-03T00:30:00Z"},
+			},
+			policy: RetentionPolicy{KeepDaily: 2},
+			want: map[string][]string{
+				"2025-12-03T00:30:00Z": {"daily"},
+				"2025-12-02T22:30:00Z": {"daily"},
+			},
+		},
+		{
+			name: "week boundary between Sunday and Monday",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-13T12:00:00Z"}, // Saturday, week 50
+				{namespace: "shop", createdAt: "2025-12-14T12:00:00Z"}, // Sunday, week 50
+				{namespace: "shop", createdAt: "2025-12-15T12:00:00Z"}, // Monday, week 51
+			},
+			policy: RetentionPolicy{KeepWeekly: 2},
+			want: map[string][]string{
+				"2025-12-15T12:00:00Z": {"weekly"},
+				"2025-12-14T12:00:00Z": {"weekly"},
+			},
+		},
+		{
+			name: "ISO week spanning the new year",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-29T12:00:00Z"}, // week 1 of 2026
+				{namespace: "shop", createdAt: "2026-01-02T12:00:00Z"}, // week 1 of 2026
+			},
+			policy: RetentionPolicy{KeepWeekly: 2},
+			want: map[string][]string{
+				"2026-01-02T12:00:00Z": {"weekly"},
+			},
+		},
+		{
+			name: "monthly",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-10-31T12:00:00Z"},
+				{namespace: "shop", createdAt: "2025-11-01T12:00:00Z"},
+				{namespace: "shop", createdAt: "2025-11-30T12:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-01T12:00:00Z"},
+			},
+			policy: RetentionPolicy{KeepMonthly: 2},
+			want: map[string][]string{
+				"2025-12-01T12:00:00Z": {"monthly"},
+				"2025-11-30T12:00:00Z": {"monthly"},
+			},
+		},
+		{
+			name: "rules add up",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-11-30T12:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-01T08:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-01T12:00:00Z"},
+			},
+			policy: RetentionPolicy{KeepLast: 1, KeepDaily: 2, KeepMonthly: 2},
+			want: map[string][]string{
+				"2025-12-01T12:00:00Z": {"last", "daily", "monthly"},
+				"2025-11-30T12:00:00Z": {"daily", "monthly"},
+			},
+		},
+		{
+			name: "invalid newest archive keeps the newest valid one",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-01T10:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-02T10:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-03T10:00:00Z", invalid: true},
+			},
+			policy: RetentionPolicy{KeepLast: 1},
+			want: map[string][]string{
+				"2025-12-03T10:00:00Z": {"last"},
+				"2025-12-02T10:00:00Z": {"newest valid backup"},
+			},
+		},
+		{
+			name: "all archives invalid",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-01T10:00:00Z", invalid: true},
+				{namespace: "shop", createdAt: "2025-12-02T10:00:00Z", invalid: true},
+			},
+			policy: RetentionPolicy{KeepLast: 1},
+			want: map[string][]string{
+				"2025-12-02T10:00:00Z": {"last"},
+			},
+		},
+		{
+			name: "namespaces are evaluated separately",
+			archives: []testArchive{
+				{namespace: "shop", createdAt: "2025-12-01T10:00:00Z"},
+				{namespace: "shop", createdAt: "2025-12-02T10:00:00Z"},
+				{namespace: "blog", createdAt: "2025-11-01T10:00:00Z"},
+			},
+			policy: RetentionPolicy{KeepLast: 1},
+			want: map[string][]string{
+				"2025-12-02T10:00:00Z": {"last"},
+				"2025-11-01T10:00:00Z": {"last"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := ApplyRetention(writeRetentionArchives(t, tt.archives), tt.policy)
+			if len(decisions) != len(tt.archives) {
+				t.Fatalf("got %d decisions, want %d", len(decisions), len(tt.archives))
+			}
+			for _, d := range decisions {
+				key := d.Archive.CreatedAt.UTC().Format(time.RFC3339)
+				want, keep := tt.want[key]
+				if d.Keep != keep || !slices.Equal(d.Reasons, want) {
+					t.Errorf("%s: keep = %t %v, want %t %v", key, d.Keep, d.Reasons, keep, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseArchiveName(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		namespace string
+		createdAt string
+		ok        bool
+	}{
+		{name: "valid", file: "backup-shop-20251203-104500.tar.gz", namespace: "shop", createdAt: "2025-12-03T10:45:00Z", ok: true},
+		{name: "namespace with dashes", file: "backup-shop-eu-1-20251203-104500.tar.gz", namespace: "shop-eu-1", createdAt: "2025-12-03T10:45:00Z", ok: true},
+		{name: "bad timestamp", file: "backup-shop-20251332-104500.tar.gz"},
+		{name: "missing dash before timestamp", file: "backup-shop20251203-104500.tar.gz"},
+		{name: "missing namespace", file: "backup-20251203-104500.tar.gz"},
+		{name: "wrong prefix", file: "snapshot-shop-20251203-104500.tar.gz"},
+		{name: "wrong suffix", file: "backup-shop-20251203-104500.tar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, createdAt, ok := ParseArchiveName(tt.file)
+			if ok != tt.ok {
+				t.Fatalf("ParseArchiveName(%q) ok = %t, want %t", tt.file, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if namespace != tt.namespace || createdAt.Format(time.RFC3339) != tt.createdAt {
+				t.Errorf("ParseArchiveName(%q) = %q, %s, want %q, %s", tt.file, namespace, createdAt.Format(time.RFC3339), tt.namespace, tt.createdAt)
+			}
+		})
+	}
+}
+
+func TestArchiveNameRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 12, 3, 10, 45, 0, 0, time.UTC)
+	namespace, got, ok := ParseArchiveName(archiveName("shop", createdAt))
+	if !ok || namespace != "shop" || !got.Equal(createdAt) {
+		t.Errorf("ParseArchiveName(archiveName) = %q, %s, %t", namespace, got, ok)
+	}
+}
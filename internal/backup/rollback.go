@@ -0,0 +1,115 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// snapshotEntry is the pre-restore state of one object the restore touches.
+// live is nil if the object did not exist before the restore.
+type snapshotEntry struct {
+	obj  *unstructured.Unstructured
+	live *unstructured.Unstructured
+}
+
+// snapshot holds the state of every object of a restore as it was before
+// anything was applied.
+type snapshot struct {
+	entries []snapshotEntry
+}
+
+// captureSnapshot reads the live state of every object in objs.
+func captureSnapshot(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, namespaceFallback string) (*snapshot, error) {
+	snap := &snapshot{entries: make([]snapshotEntry, 0, len(objs))}
+	for _, obj := range objs {
+		live, err := client.GetLive(ctx, obj, namespaceFallback)
+		if err != nil {
+			return nil, fmt.Errorf("capture pre-restore state: %w", err)
+		}
+		snap.entries = append(snap.entries, snapshotEntry{obj: obj, live: live})
+	}
+	return snap, nil
+}
+
+// writeArchive stores the captured objects at path in the same format as
+// backups, so the snapshot can be restored manually if the rollback fails.
+func (s *snapshot) writeArchive(path, namespace string) error {
+	var files []File
+	for _, e := range s.entries {
+		if e.live == nil {
+			continue
+		}
+		data, err := yaml.Marshal(e.live.Object)
+		if err != nil {
+			return fmt.Errorf("marshal %s/%s: %w", e.live.GetKind(), e.live.GetName(), err)
+		}
+		files = append(files, File{
+			Name: filepath.Join(e.live.GetNamespace(), k8s.ManifestFilename(e.live.GetKind(), e.live.GetName())),
+			Data: data,
+		})
+	}
+
+	meta, err := metadataFile(Metadata{
+		Namespace: namespace,
+		CreatedAt: time.Now().UTC(),
+		Objects:   len(files),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = CreateArchive(path, append([]File{meta}, files...))
+	return err
+}
+
+// rollback reverts the entries at the touched indices in reverse order:
+// objects that did not exist before are deleted, all others are put back
+// into their captured state. It keeps going when an object cannot be
+// reverted, returns the objects that were and joins the errors of the rest.
+func (s *snapshot) rollback(ctx context.Context, client *k8s.Client, touched []int) ([]RestoreResult, error) {
+	var results []RestoreResult
+	var errs []error
+
+	for i := len(touched) - 1; i >= 0; i-- {
+		e := s.entries[touched[i]]
+
+		if e.live == nil {
+			if err := client.DeleteObject(ctx, e.obj); err != nil {
+				errs = append(errs, fmt.Errorf("revert %s/%s: %w", e.obj.GetKind(), e.obj.GetName(), err))
+				continue
+			}
+			results = append(results, RestoreResult{
+				Kind:      e.obj.GetKind(),
+				Name:      e.obj.GetName(),
+				Namespace: e.obj.GetNamespace(),
+				Action:    k8s.ActionDeleted,
+			})
+			continue
+		}
+
+		previous := e.live.DeepCopy()
+		k8s.ClearServerFields(previous)
+		action, err := client.ApplyObject(ctx, previous, k8s.ApplyOptions{
+			Existing: k8s.ExistingRecreate,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("revert %s/%s: %w", previous.GetKind(), previous.GetName(), err))
+			continue
+		}
+		results = append(results, RestoreResult{
+			Kind:      previous.GetKind(),
+			Name:      previous.GetName(),
+			Namespace: previous.GetNamespace(),
+			Action:    action,
+		})
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// warnVersionSkew warns if the archive was taken from a newer Kubernetes
+// version than the target cluster runs, whose objects may contain fields
+// the target does not know.
+func warnVersionSkew(client *k8s.Client, archiveVersion string, opts RestoreOptions) error {
+	info, err := client.Clientset.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("get server version: %w", err)
+	}
+
+	source, err := utilversion.ParseGeneric(archiveVersion)
+	if err != nil {
+		return nil
+	}
+	target, err := utilversion.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return nil
+	}
+	if !target.LessThan(source) || (target.Major() == source.Major() && target.Minor() == source.Minor()) {
+		return nil
+	}
+
+	_, err = fmt.Fprintf(opts.Warnings, "Warning: backup was taken from Kubernetes %s but the target cluster runs %s; fields unknown to %s are %s\n",
+		archiveVersion, info.GitVersion, info.GitVersion, unknownFieldsOutcome(opts.UnknownFields))
+	return err
+}
+
+func unknownFieldsOutcome(policy k8s.UnknownFieldPolicy) string {
+	switch policy {
+	case k8s.UnknownFieldsDrop:
+		return "dropped"
+	case k8s.UnknownFieldsWarn:
+		return "reported"
+	}
+	return "not checked"
+}
+
+// checkUnknownFields compares objs against the target cluster's schema and
+// reports, or with UnknownFieldsDrop removes, the fields it does not define.
+func checkUnknownFields(client *k8s.Client, objs []*unstructured.Unstructured, opts RestoreOptions) error {
+	warnings := opts.Warnings
+	if warnings == nil {
+		warnings = io.Discard
+	}
+
+	checker, err := client.NewSchemaChecker()
+	if err != nil {
+		_, err = fmt.Fprintf(warnings, "Warning: skipping unknown field check: %v\n", err)
+		return err
+	}
+
+	drop := opts.UnknownFields == k8s.UnknownFieldsDrop
+	for _, obj := range objs {
+		fields, err := checker.UnknownFields(obj, drop)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(warnings, "Warning: %s/%s has fields unknown to the target cluster (%s): %s\n",
+			obj.GetKind(), obj.GetName(), unknownFieldsOutcome(opts.UnknownFields), strings.Join(fields, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
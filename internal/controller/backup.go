@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// reconcileBackup runs a Backup that has not finished yet. A Backup found
+// Running was interrupted by a controller restart and is run again.
+func (c *Controller) reconcileBackup(ctx context.Context, obj *unstructured.Unstructured) error {
+	var status BackupStatus
+	if err := decodeField(obj, "status", &status); err != nil {
+		return err
+	}
+	if status.Phase.done() {
+		return nil
+	}
+
+	var spec BackupSpec
+	if err := decodeField(obj, "spec", &spec); err != nil {
+		return c.failBackup(ctx, obj, err)
+	}
+	namespace := spec.Namespace
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+	if namespace != obj.GetNamespace() && !c.opts.AllowCrossNamespace {
+		return c.failBackup(ctx, obj, fmt.Errorf("backing up namespace %s from a Backup in %s is not allowed", namespace, obj.GetNamespace()))
+	}
+	if spec.Selector != "" {
+		if _, err := labels.Parse(spec.Selector); err != nil {
+			return c.failBackup(ctx, obj, fmt.Errorf("invalid selector %q: %w", spec.Selector, err))
+		}
+	}
+
+	now := metav1.Now()
+	obj, err := c.updateStatus(ctx, backupGVR, obj, &BackupStatus{Phase: PhaseRunning, StartTime: &now})
+	if err != nil {
+		return err
+	}
+	c.recorder.Eventf(obj, corev1.EventTypeNormal, "Started", "Backing up namespace %s", namespace)
+
+	dir := c.archiveDir(obj)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return c.failBackup(ctx, obj, fmt.Errorf("create archive directory: %w", err))
+	}
+	result, err := backup.BackupNamespace(ctx, c.client, namespace, backup.BackupOptions{
+		BestEffort: spec.BestEffort,
+		OutputDir:  dir,
+		Selector:   spec.Selector,
+	})
+	if err != nil {
+		return c.failBackup(ctx, obj, err)
+	}
+
+	completed := metav1.Now()
+	status = BackupStatus{
+		Phase:          PhaseCompleted,
+		StartTime:      &now,
+		CompletionTime: &completed,
+		Archive:        result.Path,
+		Objects:        int64(result.Objects),
+		Failures:       result.Failures,
+		Message:        fmt.Sprintf("Backed up %d objects", result.Objects),
+	}
+	if result.Partial() {
+		status.Phase = PhasePartiallyFailed
+		status.Message = fmt.Sprintf("Backed up %d objects, %d resource types could not be read", result.Objects, len(result.Failures))
+	}
+	if _, err := c.updateStatus(ctx, backupGVR, obj, &status); err != nil {
+		return err
+	}
+
+	if result.Partial() {
+		c.recorder.Event(obj, corev1.EventTypeWarning, "PartiallyFailed", status.Message)
+	} else {
+		c.recorder.Event(obj, corev1.EventTypeNormal, "Completed", status.Message)
+	}
+	c.logf("backup %s/%s: %s", obj.GetNamespace(), obj.GetName(), status.Message)
+	return nil
+}
+
+// failBackup marks obj Failed with cause as message.
+func (c *Controller) failBackup(ctx context.Context, obj *unstructured.Unstructured, cause error) error {
+	var status BackupStatus
+	if err := decodeField(obj, "status", &status); err != nil {
+		return err
+	}
+	completed := metav1.Now()
+	status.Phase = PhaseFailed
+	status.CompletionTime = &completed
+	status.Message = cause.Error()
+	if _, err := c.updateStatus(ctx, backupGVR, obj, &status); err != nil {
+		return err
+	}
+	c.recorder.Event(obj, corev1.EventTypeWarning, "Failed", status.Message)
+	c.logf("backup %s/%s failed: %v", obj.GetNamespace(), obj.GetName(), cause)
+	return nil
+}
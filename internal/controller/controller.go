@@ -0,0 +1,202 @@
+// Package controller reconciles Backup, Restore and BackupSchedule custom
+// resources by calling the backup and restore engines, so that teams can
+// back up and restore their namespaces by creating objects instead of
+// running the CLI.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// resyncPeriod is how often every object is reconciled even without changes.
+const resyncPeriod = 10 * time.Minute
+
+// Options configures a Controller.
+type Options struct {
+	// BackupDir is where archives are written, one directory per Backup.
+	BackupDir string
+	// Namespace restricts the controller to one namespace. Empty watches
+	// all namespaces.
+	Namespace string
+	// Workers is the number of objects reconciled in parallel.
+	Workers int
+	// AllowCrossNamespace lets a Backup or Restore act on a namespace other
+	// than its own.
+	AllowCrossNamespace bool
+	// Log receives a line per reconcile outcome.
+	Log io.Writer
+}
+
+// Controller reconciles Backup, Restore and BackupSchedule objects.
+type Controller struct {
+	client   *k8s.Client
+	opts     Options
+	queue    workqueue.TypedRateLimitingInterface[key]
+	recorder record.EventRecorder
+	listers  map[schema.GroupVersionResource]cache.GenericLister
+}
+
+// key identifies an object to reconcile.
+type key struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// New returns a Controller acting with client.
+func New(client *k8s.Client, opts Options) *Controller {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	return &Controller{
+		client: client,
+		opts:   opts,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[key](),
+			workqueue.TypedRateLimitingQueueConfig[key]{Name: "kubectl-backup"},
+		),
+		listers: make(map[schema.GroupVersionResource]cache.GenericLister),
+	}
+}
+
+// Run watches the custom resources and reconciles them until ctx is
+// cancelled. It returns once all in-flight reconciles have finished.
+func (c *Controller) Run(ctx context.Context) error {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.client.Clientset.CoreV1().Events("")})
+	defer broadcaster.Shutdown()
+	c.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "kubectl-backup-controller"})
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.client.Dynamic, resyncPeriod, c.opts.Namespace, nil)
+	var synced []cache.InformerSynced
+	for _, gvr := range []schema.GroupVersionResource{backupGVR, restoreGVR, backupScheduleGVR} {
+		informer := factory.ForResource(gvr)
+		if _, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueue(gvr, obj) },
+			UpdateFunc: func(_, obj interface{}) { c.enqueue(gvr, obj) },
+		}); err != nil {
+			return fmt.Errorf("watch %s: %w", gvr.Resource, err)
+		}
+		c.listers[gvr] = informer.Lister()
+		synced = append(synced, informer.Informer().HasSynced)
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("wait for caches to sync: %w", ctx.Err())
+	}
+	c.logf("controller started with %d workers", c.opts.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.UntilWithContext(ctx, c.worker, time.Second)
+		}()
+	}
+
+	<-ctx.Done()
+	c.queue.ShutDownWithDrain()
+	wg.Wait()
+	return nil
+}
+
+func (c *Controller) enqueue(gvr schema.GroupVersionResource, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	c.queue.Add(key{gvr: gvr, namespace: u.GetNamespace(), name: u.GetName()})
+}
+
+func (c *Controller) worker(ctx context.Context) {
+	for c.processNext(ctx) {
+	}
+}
+
+func (c *Controller) processNext(ctx context.Context) bool {
+	k, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(k)
+
+	requeueAfter, err := c.reconcile(ctx, k)
+	if err != nil {
+		c.logf("%s %s/%s: %v", k.gvr.Resource, k.namespace, k.name, err)
+		c.queue.AddRateLimited(k)
+		return true
+	}
+	c.queue.Forget(k)
+	if requeueAfter > 0 {
+		c.queue.AddAfter(k, requeueAfter)
+	}
+	return true
+}
+
+func (c *Controller) reconcile(ctx context.Context, k key) (time.Duration, error) {
+	cached, err := c.listers[k.gvr].ByNamespace(k.namespace).Get(k.name)
+	if apierrors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	obj := cached.(*unstructured.Unstructured).DeepCopy()
+
+	switch k.gvr {
+	case backupGVR:
+		return 0, c.reconcileBackup(ctx, obj)
+	case restoreGVR:
+		return c.reconcileRestore(ctx, obj)
+	case backupScheduleGVR:
+		return c.reconcileSchedule(ctx, obj)
+	}
+	return 0, nil
+}
+
+// updateStatus stores status in obj and writes it to the status
+// subresource, returning the updated object.
+func (c *Controller) updateStatus(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, status interface{}) (*unstructured.Unstructured, error) {
+	if err := encodeField(obj, "status", status); err != nil {
+		return nil, err
+	}
+	updated, err := c.client.Dynamic.Resource(gvr).Namespace(obj.GetNamespace()).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("update status: %w", err)
+	}
+	return updated, nil
+}
+
+// archiveDir is the directory the archive of a Backup is written to.
+func (c *Controller) archiveDir(obj *unstructured.Unstructured) string {
+	return filepath.Join(c.opts.BackupDir, obj.GetNamespace(), obj.GetName())
+}
+
+func (c *Controller) logf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(c.opts.Log, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
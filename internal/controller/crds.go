@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+)
+
+// crdManifest holds the CustomResourceDefinitions of Backup, Restore and
+// BackupSchedule.
+//
+//go:embed crds.yaml
+var crdManifest []byte
+
+// CRDs returns the CustomResourceDefinitions as a multi-document YAML
+// manifest.
+func CRDs() []byte {
+	return crdManifest
+}
+
+// InstallCRDs creates or updates the CustomResourceDefinitions.
+func InstallCRDs(ctx context.Context, client *k8s.Client) error {
+	for _, doc := range bytes.Split(crdManifest, []byte("\n---\n")) {
+		if err := client.ApplyYAML(ctx, "", doc); err != nil {
+			return fmt.Errorf("install CRD: %w", err)
+		}
+	}
+	// The mapper caches discovery; the new kinds must be visible to it.
+	client.Mapper.Reset()
+	return nil
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// backupPollInterval is how often a Restore waiting for its Backup checks
+// again.
+const backupPollInterval = 10 * time.Second
+
+// reconcileRestore applies the archive of a finished Backup. A Restore
+// whose Backup is still running is retried later.
+func (c *Controller) reconcileRestore(ctx context.Context, obj *unstructured.Unstructured) (time.Duration, error) {
+	var status RestoreStatus
+	if err := decodeField(obj, "status", &status); err != nil {
+		return 0, err
+	}
+	if status.Phase.done() {
+		return 0, nil
+	}
+
+	var spec RestoreSpec
+	if err := decodeField(obj, "spec", &spec); err != nil {
+		return 0, c.failRestore(ctx, obj, err)
+	}
+
+	cached, err := c.listers[backupGVR].ByNamespace(obj.GetNamespace()).Get(spec.BackupName)
+	if apierrors.IsNotFound(err) {
+		return 0, c.failRestore(ctx, obj, fmt.Errorf("backup %s not found", spec.BackupName))
+	}
+	if err != nil {
+		return 0, err
+	}
+	source := cached.(*unstructured.Unstructured)
+
+	var backupStatus BackupStatus
+	if err := decodeField(source, "status", &backupStatus); err != nil {
+		return 0, err
+	}
+	var backupSpec BackupSpec
+	if err := decodeField(source, "spec", &backupSpec); err != nil {
+		return 0, err
+	}
+	switch {
+	case backupStatus.Phase == PhaseFailed:
+		return 0, c.failRestore(ctx, obj, fmt.Errorf("backup %s failed", spec.BackupName))
+	case !backupStatus.Phase.done():
+		return backupPollInterval, nil
+	}
+	if backupSpec.Namespace != "" && backupSpec.Namespace != obj.GetNamespace() && !c.opts.AllowCrossNamespace {
+		return 0, c.failRestore(ctx, obj, fmt.Errorf("restoring a backup of namespace %s into %s is not allowed", backupSpec.Namespace, obj.GetNamespace()))
+	}
+
+	filter := backup.ObjectFilter{}
+	if spec.Selector != "" {
+		selector, err := labels.Parse(spec.Selector)
+		if err != nil {
+			return 0, c.failRestore(ctx, obj, fmt.Errorf("invalid selector %q: %w", spec.Selector, err))
+		}
+		filter.Selector = selector
+	}
+	existing, err := backup.ParseExistingPolicies(spec.Existing)
+	if err != nil {
+		return 0, c.failRestore(ctx, obj, err)
+	}
+
+	now := metav1.Now()
+	obj, err = c.updateStatus(ctx, restoreGVR, obj, &RestoreStatus{Phase: PhaseRunning, StartTime: &now, Archive: backupStatus.Archive})
+	if err != nil {
+		return 0, err
+	}
+	c.recorder.Eventf(obj, corev1.EventTypeNormal, "Started", "Restoring backup %s", spec.BackupName)
+
+	report, err := backup.RestoreNamespace(ctx, c.client, backupStatus.Archive, backup.RestoreOptions{
+		Namespace: obj.GetNamespace(),
+		Filter:    filter,
+		Existing:  existing,
+		DryRun:    spec.DryRun,
+	})
+
+	completed := metav1.Now()
+	status = RestoreStatus{
+		Phase:          PhaseCompleted,
+		StartTime:      &now,
+		CompletionTime: &completed,
+		Archive:        backupStatus.Archive,
+		Created:        int64(report.Count(k8s.ActionCreated)),
+		Updated:        int64(report.Count(k8s.ActionUpdated)),
+		Recreated:      int64(report.Count(k8s.ActionRecreated)),
+		Skipped:        int64(report.Count(k8s.ActionSkipped)),
+	}
+	status.Message = fmt.Sprintf("Restored %d objects: %d created, %d updated, %d recreated, %d skipped",
+		len(report.Results), status.Created, status.Updated, status.Recreated, status.Skipped)
+	if spec.DryRun {
+		status.Message = "Dry run: " + status.Message
+	}
+	if err != nil {
+		status.Phase = PhaseFailed
+		status.Message = err.Error()
+	}
+	if _, updateErr := c.updateStatus(ctx, restoreGVR, obj, &status); updateErr != nil {
+		return 0, updateErr
+	}
+
+	if err != nil {
+		c.recorder.Event(obj, corev1.EventTypeWarning, "Failed", status.Message)
+		c.logf("restore %s/%s failed: %v", obj.GetNamespace(), obj.GetName(), err)
+		return 0, nil
+	}
+	c.recorder.Event(obj, corev1.EventTypeNormal, "Completed", status.Message)
+	c.logf("restore %s/%s: %s", obj.GetNamespace(), obj.GetName(), status.Message)
+	return 0, nil
+}
+
+// failRestore marks obj Failed with cause as message.
+func (c *Controller) failRestore(ctx context.Context, obj *unstructured.Unstructured, cause error) error {
+	var status RestoreStatus
+	if err := decodeField(obj, "status", &status); err != nil {
+		return err
+	}
+	completed := metav1.Now()
+	status.Phase = PhaseFailed
+	status.CompletionTime = &completed
+	status.Message = cause.Error()
+	if _, err := c.updateStatus(ctx, restoreGVR, obj, &status); err != nil {
+		return err
+	}
+	c.recorder.Event(obj, corev1.EventTypeWarning, "Failed", status.Message)
+	c.logf("restore %s/%s failed: %v", obj.GetNamespace(), obj.GetName(), cause)
+	return nil
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// scheduleLabel is set on Backups created by a BackupSchedule to its name.
+const scheduleLabel = Group + "/schedule"
+
+// reconcileSchedule creates a Backup when the schedule is due, deletes the
+// Backups beyond keepLast and returns how long to wait for the next run.
+func (c *Controller) reconcileSchedule(ctx context.Context, obj *unstructured.Unstructured) (time.Duration, error) {
+	var spec BackupScheduleSpec
+	if err := decodeField(obj, "spec", &spec); err != nil {
+		return 0, err
+	}
+	var status BackupScheduleStatus
+	if err := decodeField(obj, "status", &status); err != nil {
+		return 0, err
+	}
+
+	schedule, err := cron.ParseStandard(spec.Schedule)
+	if err != nil {
+		message := fmt.Sprintf("invalid schedule %q: %v", spec.Schedule, err)
+		if status.Message != message {
+			status.Message = message
+			if _, err := c.updateStatus(ctx, backupScheduleGVR, obj, &status); err != nil {
+				return 0, err
+			}
+			c.recorder.Event(obj, corev1.EventTypeWarning, "InvalidSchedule", message)
+		}
+		return 0, nil
+	}
+	if spec.Suspend {
+		return 0, nil
+	}
+
+	last := obj.GetCreationTimestamp().Time
+	if status.LastScheduleTime != nil {
+		last = status.LastScheduleTime.Time
+	}
+	now := time.Now()
+	due := schedule.Next(last)
+	if due.After(now) {
+		return time.Until(due) + time.Second, nil
+	}
+	// Runs missed while the controller was down collapse into one.
+	for next := schedule.Next(due); !next.After(now); next = schedule.Next(next) {
+		due = next
+	}
+
+	name, err := c.createScheduledBackup(ctx, obj, spec, due)
+	if err != nil {
+		return 0, err
+	}
+	scheduled := metav1.NewTime(due)
+	status = BackupScheduleStatus{LastScheduleTime: &scheduled, LastBackup: name}
+	obj, err = c.updateStatus(ctx, backupScheduleGVR, obj, &status)
+	if err != nil {
+		return 0, err
+	}
+	c.recorder.Eventf(obj, corev1.EventTypeNormal, "BackupCreated", "Created Backup %s", name)
+	c.logf("schedule %s/%s: created Backup %s", obj.GetNamespace(), obj.GetName(), name)
+
+	if spec.KeepLast > 0 {
+		if err := c.pruneScheduledBackups(ctx, obj, int(spec.KeepLast)); err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Until(schedule.Next(now)) + time.Second, nil
+}
+
+// createScheduledBackup creates the Backup of schedule for the run due at
+// due. The name is derived from the run time, so a retried reconcile does
+// not create a second Backup for the same run.
+func (c *Controller) createScheduledBackup(ctx context.Context, schedule *unstructured.Unstructured, spec BackupScheduleSpec, due time.Time) (string, error) {
+	name := fmt.Sprintf("%s-%d", schedule.GetName(), due.Unix())
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(Group + "/" + Version)
+	obj.SetKind("Backup")
+	obj.SetName(name)
+	obj.SetNamespace(schedule.GetNamespace())
+	obj.SetLabels(map[string]string{scheduleLabel: schedule.GetName()})
+	controller := true
+	obj.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: schedule.GetAPIVersion(),
+		Kind:       schedule.GetKind(),
+		Name:       schedule.GetName(),
+		UID:        schedule.GetUID(),
+		Controller: &controller,
+	}})
+	if err := encodeField(obj, "spec", &spec.Template); err != nil {
+		return "", err
+	}
+
+	_, err := c.client.Dynamic.Resource(backupGVR).Namespace(obj.GetNamespace()).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("create Backup %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// pruneScheduledBackups deletes the finished Backups of schedule beyond the
+// newest keep, together with their archives.
+func (c *Controller) pruneScheduledBackups(ctx context.Context, schedule *unstructured.Unstructured, keep int) error {
+	selector := labels.SelectorFromSet(labels.Set{scheduleLabel: schedule.GetName()})
+	cached, err := c.listers[backupGVR].ByNamespace(schedule.GetNamespace()).List(selector)
+	if err != nil {
+		return fmt.Errorf("list Backups: %w", err)
+	}
+
+	var finished []*unstructured.Unstructured
+	for _, o := range cached {
+		obj := o.(*unstructured.Unstructured)
+		var status BackupStatus
+		if err := decodeField(obj, "status", &status); err != nil {
+			return err
+		}
+		if status.Phase.done() {
+			finished = append(finished, obj)
+		}
+	}
+	if len(finished) <= keep {
+		return nil
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].GetCreationTimestamp().After(finished[j].GetCreationTimestamp().Time)
+	})
+
+	for _, obj := range finished[keep:] {
+		if err := os.RemoveAll(c.archiveDir(obj)); err != nil {
+			return fmt.Errorf("delete archive of Backup %s: %w", obj.GetName(), err)
+		}
+		err := c.client.Dynamic.Resource(backupGVR).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete Backup %s: %w", obj.GetName(), err)
+		}
+		c.logf("schedule %s/%s: deleted Backup %s", schedule.GetNamespace(), schedule.GetName(), obj.GetName())
+	}
+	return nil
+}
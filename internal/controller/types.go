@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Group and Version of the custom resources.
+const (
+	Group   = "kubectl-backup.io"
+	Version = "v1alpha1"
+)
+
+var (
+	backupGVR         = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "backups"}
+	restoreGVR        = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "restores"}
+	backupScheduleGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "backupschedules"}
+)
+
+// Phase is the lifecycle state of a Backup or Restore.
+type Phase string
+
+const (
+	PhaseRunning         Phase = "Running"
+	PhaseCompleted       Phase = "Completed"
+	PhasePartiallyFailed Phase = "PartiallyFailed"
+	PhaseFailed          Phase = "Failed"
+)
+
+// done reports whether p is a final phase.
+func (p Phase) done() bool {
+	return p == PhaseCompleted || p == PhasePartiallyFailed || p == PhaseFailed
+}
+
+// BackupSpec is the spec of a Backup.
+type BackupSpec struct {
+	Namespace  string `json:"namespace,omitempty"`
+	Selector   string `json:"selector,omitempty"`
+	BestEffort bool   `json:"bestEffort,omitempty"`
+}
+
+// BackupStatus is the status of a Backup.
+type BackupStatus struct {
+	Phase          Phase                    `json:"phase,omitempty"`
+	StartTime      *metav1.Time             `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time             `json:"completionTime,omitempty"`
+	Archive        string                   `json:"archive,omitempty"`
+	Objects        int64                    `json:"objects,omitempty"`
+	Failures       []backup.ResourceFailure `json:"failures,omitempty"`
+	Message        string                   `json:"message,omitempty"`
+}
+
+// RestoreSpec is the spec of a Restore.
+type RestoreSpec struct {
+	BackupName string   `json:"backupName"`
+	Selector   string   `json:"selector,omitempty"`
+	Existing   []string `json:"existing,omitempty"`
+	DryRun     bool     `json:"dryRun,omitempty"`
+}
+
+// RestoreStatus is the status of a Restore.
+type RestoreStatus struct {
+	Phase          Phase        `json:"phase,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Archive        string       `json:"archive,omitempty"`
+	Created        int64        `json:"created,omitempty"`
+	Updated        int64        `json:"updated,omitempty"`
+	Recreated      int64        `json:"recreated,omitempty"`
+	Skipped        int64        `json:"skipped,omitempty"`
+	Message        string       `json:"message,omitempty"`
+}
+
+// BackupScheduleSpec is the spec of a BackupSchedule.
+type BackupScheduleSpec struct {
+	Schedule string     `json:"schedule"`
+	Suspend  bool       `json:"suspend,omitempty"`
+	KeepLast int64      `json:"keepLast,omitempty"`
+	Template BackupSpec `json:"template,omitempty"`
+}
+
+// BackupScheduleStatus is the status of a BackupSchedule.
+type BackupScheduleStatus struct {
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	LastBackup       string       `json:"lastBackup,omitempty"`
+	Message          string       `json:"message,omitempty"`
+}
+
+// decodeField decodes the field (spec or status) of obj into out.
+func decodeField(obj *unstructured.Unstructured, field string, out interface{}) error {
+	content, _, err := unstructured.NestedMap(obj.Object, field)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", field, err)
+	}
+	if content == nil {
+		return nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, out); err != nil {
+		return fmt.Errorf("decode %s: %w", field, err)
+	}
+	return nil
+}
+
+// encodeField stores in (a pointer to a spec or status) as the field of obj.
+func encodeField(obj *unstructured.Unstructured, field string, in interface{}) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", field, err)
+	}
+	return unstructured.SetNestedMap(obj.Object, content, field)
+}
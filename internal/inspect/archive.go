@@ -0,0 +1,214 @@
+package inspect
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/list"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const redacted = "**REDACTED**"
+
+// lastAppliedAnnotation holds a full copy of the object, including Secret data.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// entry is a decoded manifest from an archive.
+type entry struct {
+	name string
+	obj  *unstructured.Unstructured
+}
+
+// readArchive extracts the archive at path and decodes its manifests.
+func readArchive(path string) (*backup.Metadata, []entry, error) {
+	files, err := backup.ExtractArchive(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("extract archive: %w", err)
+	}
+
+	meta, files, err := backup.SplitMetadata(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read archive metadata: %w", err)
+	}
+
+	entries := make([]entry, 0, len(files))
+	for _, f := range files {
+		obj, err := k8s.DecodeManifest(f.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode manifest %s: %w", f.Name, err)
+		}
+		entries = append(entries, entry{name: f.Name, obj: obj})
+	}
+
+	return meta, entries, nil
+}
+
+// Archive is the -o json|yaml document of the inspect command. Metadata is
+// missing for archives written before it was recorded.
+type Archive struct {
+	Path     string             `json:"path"`
+	Size     int64              `json:"size"`
+	Metadata *backup.Metadata   `json:"metadata,omitempty"`
+	Objects  []k8s.ResourceInfo `json:"objects"`
+}
+
+// InspectArchive prints the metadata of the archive at path followed by a
+// table of the objects it contains, or the same information in format.
+func InspectArchive(path string, format output.Format, out io.Writer) error {
+	info, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("stat archive: %w", err)
+	}
+
+	meta, entries, err := readArchive(path)
+	if err != nil {
+		return err
+	}
+
+	resources := make([]k8s.ResourceInfo, 0, len(entries))
+	for _, e := range entries {
+		resources = append(resources, k8s.ResourceInfoFromObject(e.obj))
+	}
+
+	switch format {
+	case output.JSON, output.YAML:
+		return output.Write(out, format, Archive{Path: path, Size: info.Size(), Metadata: meta, Objects: resources})
+	case output.Name:
+		return list.PrintNames(out, resources)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	if _, err := fmt.Fprintf(w, "Archive:\t%s\n", path); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "Size:\t%d bytes\n", info.Size()); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if meta != nil {
+		if _, err := fmt.Fprintf(w, "Namespace:\t%s\nCreated:\t%s\n",
+			meta.Namespace,
+			meta.CreatedAt.Format("2006-01-02 15:04:05 MST"),
+		); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+		if meta.ServerVersion != "" {
+			if _, err := fmt.Fprintf(w, "Server:\t%s\n", meta.ServerVersion); err != nil {
+				return fmt.Errorf("failed to write metadata: %w", err)
+			}
+		}
+		for _, f := range meta.Failures {
+			if _, err := fmt.Fprintf(w, "Missing:\t%s (%s)\n", f.Kind, f.Error); err != nil {
+				return fmt.Errorf("failed to write metadata: %w", err)
+			}
+		}
+	} else {
+		// Older archives carry no metadata entry; derive what we can.
+		if _, err := fmt.Fprintf(w, "Namespace:\t%s\n", strings.Join(namespaces(resources), ", ")); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+		if _, createdAt, ok := backup.ParseArchiveName(filepath.Base(path)); ok {
+			if _, err := fmt.Fprintf(w, "Created:\t%s\n", createdAt.Format("2006-01-02 15:04:05 MST")); err != nil {
+				return fmt.Errorf("failed to write metadata: %w", err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(out); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return list.PrintResources(out, resources, format == output.Wide)
+}
+
+// ShowObject prints the manifest of the object referenced by ref (kind/name)
+// from the archive at path, as YAML unless format is JSON or Name. Secret
+// values are redacted unless revealSecrets is set.
+func ShowObject(path, ref string, revealSecrets bool, format output.Format, out io.Writer) error {
+	objRef, err := k8s.ParseObjectRef(ref)
+	if err != nil {
+		return err
+	}
+
+	_, entries, err := readArchive(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !objRef.Matches(e.obj) {
+			continue
+		}
+
+		obj := e.obj
+		if !revealSecrets && obj.GetKind() == "Secret" {
+			obj = redactSecret(obj)
+		}
+
+		switch format {
+		case output.Name:
+			return output.WriteNames(out, []string{output.ObjectName(obj.GetKind(), obj.GetName())})
+		case output.JSON:
+			return output.Write(out, format, obj.Object)
+		}
+
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("marshal %s: %w", objRef, err)
+		}
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s not found in archive %s", objRef, path)
+}
+
+// redactSecret returns a copy of a Secret with all values replaced.
+func redactSecret(secret *unstructured.Unstructured) *unstructured.Unstructured {
+	out := secret.DeepCopy()
+
+	for _, field := range []string{"data", "stringData"} {
+		values, found, err := unstructured.NestedMap(out.Object, field)
+		if err != nil || !found {
+			continue
+		}
+		for key := range values {
+			values[key] = redacted
+		}
+		_ = unstructured.SetNestedMap(out.Object, values, field)
+	}
+
+	if annotations := out.GetAnnotations(); annotations[lastAppliedAnnotation] != "" {
+		annotations[lastAppliedAnnotation] = redacted
+		out.SetAnnotations(annotations)
+	}
+
+	return out
+}
+
+func namespaces(resources []k8s.ResourceInfo) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, r := range resources {
+		if r.Namespace == "" || seen[r.Namespace] {
+			continue
+		}
+		seen[r.Namespace] = true
+		out = append(out, r.Namespace)
+	}
+	sort.Strings(out)
+	return out
+}
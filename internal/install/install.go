@@ -0,0 +1,322 @@
+// Package install renders the objects that run scheduled backups inside the
+// cluster: a ServiceAccount allowed to read the backed-up namespaces, a
+// volume for the archives and a CronJob per namespace running this binary
+// with the in-cluster configuration.
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/preflight"
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// backupDir is where the archive volume is mounted and the working
+// directory of the backup container, so archives land on the volume.
+const backupDir = "/backups"
+
+// maxCronJobName is the longest CronJob name the API server accepts; the
+// controller appends an 11 character suffix to the Job names.
+const maxCronJobName = 52
+
+// Options describes an in-cluster backup installation.
+type Options struct {
+	// Name is used for the ServiceAccount, the RBAC objects, the Secret and
+	// the claim, and as the prefix of the CronJobs.
+	Name string
+	// Namespace is where the ServiceAccount, Secret, claim and CronJobs live.
+	Namespace string
+	// BackupNamespaces are the namespaces backed up, one CronJob each.
+	BackupNamespaces []string
+	// Image is a container image with this binary as its entrypoint.
+	Image string
+	// Schedule is the cron expression of the CronJobs.
+	Schedule string
+	// BestEffort passes --best-effort to the backups.
+	BestEffort bool
+
+	// ClaimName is the PersistentVolumeClaim the archives are written to.
+	// It defaults to Name.
+	ClaimName string
+	// StorageSize renders a claim of this size. If empty the claim must
+	// already exist.
+	StorageSize string
+	// StorageClass is the StorageClass of a rendered claim.
+	StorageClass string
+	// StorageAccessMode is the access mode of a rendered claim.
+	StorageAccessMode string
+	// StorageCredentials are stored in a Secret whose keys are exposed to
+	// the backup container as environment variables.
+	StorageCredentials map[string]string
+}
+
+// Validate checks that opts describe a valid installation.
+func (o Options) Validate() error {
+	if errs := validation.IsDNS1123Label(o.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", o.Name, strings.Join(errs, ", "))
+	}
+	if o.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if len(o.BackupNamespaces) == 0 {
+		return fmt.Errorf("at least one namespace to back up is required")
+	}
+	for _, ns := range o.BackupNamespaces {
+		if len(cronJobName(o.Name, ns)) > maxCronJobName {
+			return fmt.Errorf("CronJob name %q is longer than %d characters, use a shorter --name", cronJobName(o.Name, ns), maxCronJobName)
+		}
+	}
+	if o.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if _, err := cron.ParseStandard(o.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", o.Schedule, err)
+	}
+	if o.StorageSize != "" {
+		if _, err := resource.ParseQuantity(o.StorageSize); err != nil {
+			return fmt.Errorf("invalid storage size %q: %w", o.StorageSize, err)
+		}
+	}
+	for key := range o.StorageCredentials {
+		if errs := validation.IsEnvVarName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid storage credential name %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+func cronJobName(name, namespace string) string {
+	return name + "-" + namespace
+}
+
+// Render returns the objects of the installation, in the order they should
+// be applied.
+func Render(opts Options) ([]*unstructured.Unstructured, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	claimName := opts.ClaimName
+	if claimName == "" {
+		claimName = opts.Name
+	}
+	labels := map[string]string{"app.kubernetes.io/name": "kubectl-backup", "app.kubernetes.io/instance": opts.Name}
+	meta := func(name, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}
+	}
+
+	var typed []runtime.Object
+	typed = append(typed,
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Namespace},
+		},
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta(opts.Name, opts.Namespace),
+		},
+	)
+
+	// The ClusterRole holds exactly what a backup reads and is only bound
+	// in the backed-up namespaces.
+	var perms []preflight.Permission
+	for _, ns := range opts.BackupNamespaces {
+		perms = append(perms, preflight.BackupPermissions(ns)...)
+	}
+	typed = append(typed, &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: meta(opts.Name, ""),
+		Rules:      preflight.PolicyRules(perms),
+	})
+	for _, ns := range opts.BackupNamespaces {
+		typed = append(typed, &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: meta(opts.Name, ns),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: opts.Name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: opts.Name, Namespace: opts.Namespace}},
+		})
+	}
+
+	var envFrom []corev1.EnvFromSource
+	if len(opts.StorageCredentials) > 0 {
+		typed = append(typed, &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: meta(opts.Name, opts.Namespace),
+			Type:       corev1.SecretTypeOpaque,
+			StringData: opts.StorageCredentials,
+		})
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: opts.Name}},
+		})
+	}
+
+	if opts.StorageSize != "" {
+		accessMode := corev1.ReadWriteOnce
+		if opts.StorageAccessMode != "" {
+			accessMode = corev1.PersistentVolumeAccessMode(opts.StorageAccessMode)
+		}
+		pvc := &corev1.PersistentVolumeClaim{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+			ObjectMeta: meta(claimName, opts.Namespace),
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{accessMode},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(opts.StorageSize)},
+				},
+			},
+		}
+		if opts.StorageClass != "" {
+			pvc.Spec.StorageClassName = &opts.StorageClass
+		}
+		typed = append(typed, pvc)
+	}
+
+	backoffLimit := int32(1)
+	historyLimit := int32(3)
+	noEscalation := false
+	readOnlyRoot := true
+	for _, ns := range opts.BackupNamespaces {
+		args := []string{"backup", ns}
+		if opts.BestEffort {
+			args = append(args, "--best-effort")
+		}
+		typed = append(typed, &batchv1.CronJob{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+			ObjectMeta: meta(cronJobName(opts.Name, ns), opts.Namespace),
+			Spec: batchv1.CronJobSpec{
+				Schedule:                   opts.Schedule,
+				ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+				SuccessfulJobsHistoryLimit: &historyLimit,
+				FailedJobsHistoryLimit:     &historyLimit,
+				JobTemplate: batchv1.JobTemplateSpec{
+					Spec: batchv1.JobSpec{
+						BackoffLimit: &backoffLimit,
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{Labels: labels},
+							Spec: corev1.PodSpec{
+								ServiceAccountName: opts.Name,
+								RestartPolicy:      corev1.RestartPolicyNever,
+								Containers: []corev1.Container{{
+									Name:       "backup",
+									Image:      opts.Image,
+									Args:       args,
+									WorkingDir: backupDir,
+									EnvFrom:    envFrom,
+									VolumeMounts: []corev1.VolumeMount{{
+										Name:      "backups",
+										MountPath: backupDir,
+									}},
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: &noEscalation,
+										ReadOnlyRootFilesystem:   &readOnlyRoot,
+									},
+								}},
+								Volumes: []corev1.Volume{{
+									Name: "backups",
+									VolumeSource: corev1.VolumeSource{
+										PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	objs := make([]*unstructured.Unstructured, 0, len(typed))
+	for _, t := range typed {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(t)
+		if err != nil {
+			return nil, fmt.Errorf("convert %s: %w", t.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		k8s.ClearServerFields(obj)
+		removeEmptyFields(obj.Object)
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// removeEmptyFields drops the null creationTimestamp fields typed objects
+// carry, also in nested templates, and empty metadata, spec, resources and
+// status maps.
+func removeEmptyFields(m map[string]interface{}) {
+	for key, value := range m {
+		switch v := value.(type) {
+		case nil:
+			if key == "creationTimestamp" {
+				delete(m, key)
+			}
+		case map[string]interface{}:
+			removeEmptyFields(v)
+			if len(v) == 0 && (key == "metadata" || key == "spec" || key == "resources" || key == "status") {
+				delete(m, key)
+			}
+		case []interface{}:
+			for _, item := range v {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					removeEmptyFields(itemMap)
+				}
+			}
+		}
+	}
+}
+
+// Manifest encodes objs as a multi-document YAML manifest.
+func Manifest(objs []*unstructured.Unstructured) ([]byte, error) {
+	docs := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		docs = append(docs, string(data))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// Apply creates or updates objs in the cluster, in order. With dryRun every
+// write is a server-side dry run.
+func Apply(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, dryRun bool) ([]backup.RestoreResult, error) {
+	results := make([]backup.RestoreResult, 0, len(objs))
+	for _, obj := range objs {
+		action, err := client.ApplyObject(ctx, obj, k8s.ApplyOptions{Existing: k8s.ExistingUpdate, DryRun: dryRun})
+		if err != nil {
+			return results, err
+		}
+		results = append(results, backup.RestoreResult{
+			Kind:      obj.GetKind(),
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Action:    action,
+		})
+	}
+	return results, nil
+}
+
+// ParseCredentials parses KEY=VALUE pairs into a map.
+func ParseCredentials(pairs []string) (map[string]string, error) {
+	creds := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid storage credential %q, expected KEY=VALUE", pair)
+		}
+		creds[key] = value
+	}
+	return creds, nil
+}
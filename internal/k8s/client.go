@@ -1,51 +1,46 @@
 package k8s
 
 import (
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 )
 
-// Client wraps Kubernetes clientset
+// Client wraps the typed and dynamic Kubernetes clients for one cluster
 type Client struct {
 	Clientset *kubernetes.Clientset
+	Dynamic   dynamic.Interface
+	Mapper    *restmapper.DeferredDiscoveryRESTMapper
+	Config    *rest.Config
+	// Retry controls the retries of listing and applying objects. The zero
+	// value does not retry.
+	Retry RetryOptions
 }
 
-// NewClient creates a new Kubernetes client from kubeconfig
-func NewClient(kubeconfigPath string) (*Client, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-	if err != nil {
-		return nil, err
-	}
-
+// NewClientForConfig creates a new Kubernetes client from a REST config
+func NewClientForConfig(config *rest.Config) (*Client, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		Clientset: clientset,
-	}, nil
-}
-
-// NewClientFromDefault creates a new Kubernetes client using default kubeconfig location
-func NewClientFromDefault() (*Client, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		loadingRules,
-		configOverrides,
-	).ClientConfig()
+	disco, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	dyn, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
 		Clientset: clientset,
+		Dynamic:   dyn,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco)),
+		Config:    config,
 	}, nil
 }
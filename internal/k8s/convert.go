@@ -0,0 +1,159 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// conversion upgrades objects of a deprecated apiVersion to apiVersion. The
+// optional convert func adjusts fields whose shape changed between versions.
+type conversion struct {
+	apiVersion string
+	convert    func(obj *unstructured.Unstructured) error
+}
+
+// deprecatedVersions maps "apiVersion Kind" of API versions removed from
+// current Kubernetes releases to their GA replacement.
+var deprecatedVersions = map[string]conversion{
+	"extensions/v1beta1 Ingress":                           {"networking.k8s.io/v1", convertIngress},
+	"networking.k8s.io/v1beta1 Ingress":                    {"networking.k8s.io/v1", convertIngress},
+	"networking.k8s.io/v1beta1 IngressClass":               {"networking.k8s.io/v1", nil},
+	"batch/v1beta1 CronJob":                                {"batch/v1", nil},
+	"batch/v2alpha1 CronJob":                               {"batch/v1", nil},
+	"policy/v1beta1 PodDisruptionBudget":                   {"policy/v1", nil},
+	"extensions/v1beta1 Deployment":                        {"apps/v1", convertWorkload},
+	"apps/v1beta1 Deployment":                              {"apps/v1", convertWorkload},
+	"apps/v1beta2 Deployment":                              {"apps/v1", convertWorkload},
+	"extensions/v1beta1 DaemonSet":                         {"apps/v1", convertWorkload},
+	"apps/v1beta2 DaemonSet":                               {"apps/v1", convertWorkload},
+	"apps/v1beta1 StatefulSet":                             {"apps/v1", convertWorkload},
+	"apps/v1beta2 StatefulSet":                             {"apps/v1", convertWorkload},
+	"extensions/v1beta1 ReplicaSet":                        {"apps/v1", convertWorkload},
+	"apps/v1beta2 ReplicaSet":                              {"apps/v1", convertWorkload},
+	"extensions/v1beta1 NetworkPolicy":                     {"networking.k8s.io/v1", nil},
+	"autoscaling/v2beta2 HorizontalPodAutoscaler":          {"autoscaling/v2", nil},
+	"rbac.authorization.k8s.io/v1beta1 Role":               {"rbac.authorization.k8s.io/v1", nil},
+	"rbac.authorization.k8s.io/v1beta1 RoleBinding":        {"rbac.authorization.k8s.io/v1", nil},
+	"rbac.authorization.k8s.io/v1beta1 ClusterRole":        {"rbac.authorization.k8s.io/v1", nil},
+	"rbac.authorization.k8s.io/v1beta1 ClusterRoleBinding": {"rbac.authorization.k8s.io/v1", nil},
+	"scheduling.k8s.io/v1beta1 PriorityClass":              {"scheduling.k8s.io/v1", nil},
+	"storage.k8s.io/v1beta1 StorageClass":                  {"storage.k8s.io/v1", nil},
+	"storage.k8s.io/v1beta1 CSIDriver":                     {"storage.k8s.io/v1", nil},
+}
+
+// ConvertDeprecated upgrades obj in place if its apiVersion is a known
+// deprecated version. It returns the original apiVersion and whether the
+// object was converted.
+func ConvertDeprecated(obj *unstructured.Unstructured) (string, bool, error) {
+	from := obj.GetAPIVersion()
+	conv, ok := deprecatedVersions[from+" "+obj.GetKind()]
+	if !ok {
+		return from, false, nil
+	}
+
+	if conv.convert != nil {
+		if err := conv.convert(obj); err != nil {
+			return from, false, fmt.Errorf("convert %s/%s from %s: %w", obj.GetKind(), obj.GetName(), from, err)
+		}
+	}
+	obj.SetAPIVersion(conv.apiVersion)
+	return from, true, nil
+}
+
+// convertWorkload fills in spec.selector, which apps/v1 requires but older
+// versions defaulted from the pod template labels, and drops fields removed
+// in apps/v1.
+func convertWorkload(obj *unstructured.Unstructured) error {
+	if _, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "selector"); !ok {
+		labels, _, err := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		if err != nil {
+			return err
+		}
+		if len(labels) == 0 {
+			return fmt.Errorf("spec.selector is missing and the pod template has no labels")
+		}
+		matchLabels := make(map[string]interface{}, len(labels))
+		for k, v := range labels {
+			matchLabels[k] = v
+		}
+		if err := unstructured.SetNestedMap(obj.Object, matchLabels, "spec", "selector", "matchLabels"); err != nil {
+			return err
+		}
+	}
+
+	unstructured.RemoveNestedField(obj.Object, "spec", "rollbackTo")
+	unstructured.RemoveNestedField(obj.Object, "spec", "templateGeneration")
+	return nil
+}
+
+// convertIngress rewrites v1beta1 backends ({serviceName, servicePort}) into
+// the networking.k8s.io/v1 shape ({service: {name, port}}), renames
+// spec.backend to spec.defaultBackend and sets the now required pathType.
+func convertIngress(obj *unstructured.Unstructured) error {
+	spec, ok, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !ok {
+		return err
+	}
+
+	if backend, ok := spec["backend"].(map[string]interface{}); ok {
+		spec["defaultBackend"] = convertIngressBackend(backend)
+		delete(spec, "backend")
+	}
+
+	rules, _ := spec["rules"].([]interface{})
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		http, ok := rule["http"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, _ := http["paths"].([]interface{})
+		for _, p := range paths {
+			path, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				path["backend"] = convertIngressBackend(backend)
+			}
+			if _, ok := path["pathType"]; !ok {
+				path["pathType"] = "ImplementationSpecific"
+			}
+		}
+	}
+
+	return unstructured.SetNestedMap(obj.Object, spec, "spec")
+}
+
+func convertIngressBackend(backend map[string]interface{}) map[string]interface{} {
+	name, hasName := backend["serviceName"]
+	port, hasPort := backend["servicePort"]
+	if !hasName && !hasPort {
+		// Resource backends have the same shape in both versions.
+		return backend
+	}
+
+	servicePort := map[string]interface{}{}
+	switch p := port.(type) {
+	case int64:
+		servicePort["number"] = p
+	case string:
+		if n, err := strconv.ParseInt(p, 10, 32); err == nil {
+			servicePort["number"] = n
+		} else {
+			servicePort["name"] = p
+		}
+	}
+
+	return map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": name,
+			"port": servicePort,
+		},
+	}
+}
@@ -0,0 +1,232 @@
+package k8s_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func decode(t *testing.T, manifest string) *unstructured.Unstructured {
+	t.Helper()
+	data, err := yaml.YAMLToJSON([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestConvertDeprecated(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		converted bool
+	}{
+		{
+			name: "extensions ingress",
+			in: `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata: {name: web}
+spec:
+  backend: {serviceName: fallback, servicePort: 80}
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /
+        backend: {serviceName: web, servicePort: "8080"}
+      - path: /api
+        pathType: Prefix
+        backend: {serviceName: api, servicePort: http}
+`,
+			want: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata: {name: web}
+spec:
+  defaultBackend: {service: {name: fallback, port: {number: 80}}}
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /
+        pathType: ImplementationSpecific
+        backend: {service: {name: web, port: {number: 8080}}}
+      - path: /api
+        pathType: Prefix
+        backend: {service: {name: api, port: {name: http}}}
+`,
+			converted: true,
+		},
+		{
+			name: "ingress resource backend",
+			in: `
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata: {name: assets}
+spec:
+  backend: {resource: {apiGroup: storage.example.com, kind: Bucket, name: assets}}
+`,
+			want: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata: {name: assets}
+spec:
+  defaultBackend: {resource: {apiGroup: storage.example.com, kind: Bucket, name: assets}}
+`,
+			converted: true,
+		},
+		{
+			name: "cronjob",
+			in: `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata: {name: report}
+spec: {schedule: "0 * * * *"}
+`,
+			want: `
+apiVersion: batch/v1
+kind: CronJob
+metadata: {name: report}
+spec: {schedule: "0 * * * *"}
+`,
+			converted: true,
+		},
+		{
+			name: "pod disruption budget",
+			in: `
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata: {name: web}
+spec: {minAvailable: 1}
+`,
+			want: `
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata: {name: web}
+spec: {minAvailable: 1}
+`,
+			converted: true,
+		},
+		{
+			name: "deployment without selector",
+			in: `
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: {name: web}
+spec:
+  rollbackTo: {revision: 2}
+  templateGeneration: 3
+  template:
+    metadata: {labels: {app: web}}
+`,
+			want: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  selector: {matchLabels: {app: web}}
+  template:
+    metadata: {labels: {app: web}}
+`,
+			converted: true,
+		},
+		{
+			name: "statefulset keeps its selector",
+			in: `
+apiVersion: apps/v1beta2
+kind: StatefulSet
+metadata: {name: db}
+spec:
+  selector: {matchLabels: {app: db, tier: data}}
+  template:
+    metadata: {labels: {app: db, tier: data, version: "2"}}
+`,
+			want: `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata: {name: db}
+spec:
+  selector: {matchLabels: {app: db, tier: data}}
+  template:
+    metadata: {labels: {app: db, tier: data, version: "2"}}
+`,
+			converted: true,
+		},
+		{
+			name: "current version is unchanged",
+			in: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  templateGeneration: 3
+`,
+			want: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  templateGeneration: 3
+`,
+		},
+		{
+			name: "custom resource is unchanged",
+			in: `
+apiVersion: example.com/v1beta1
+kind: Ingress
+metadata: {name: web}
+spec: {backend: {serviceName: web}}
+`,
+			want: `
+apiVersion: example.com/v1beta1
+kind: Ingress
+metadata: {name: web}
+spec: {backend: {serviceName: web}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := decode(t, tt.in)
+			from, converted, err := k8s.ConvertDeprecated(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converted != tt.converted {
+				t.Errorf("converted = %t, want %t", converted, tt.converted)
+			}
+			if want := decode(t, tt.in).GetAPIVersion(); from != want {
+				t.Errorf("from = %q, want %q", from, want)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(obj.Object, want.Object) {
+				t.Errorf("ConvertDeprecated =\n%v\nwant\n%v", obj.Object, want.Object)
+			}
+		})
+	}
+}
+
+func TestConvertDeprecatedRequiresSelector(t *testing.T) {
+	obj := decode(t, `
+apiVersion: apps/v1beta1
+kind: Deployment
+metadata: {name: web}
+spec:
+  template:
+    metadata: {name: web}
+`)
+	if _, converted, err := k8s.ConvertDeprecated(obj); err == nil || converted {
+		t.Errorf("ConvertDeprecated = %t, %v, want an error for a template without labels", converted, err)
+	}
+	if obj.GetAPIVersion() != "apps/v1beta1" {
+		t.Errorf("apiVersion = %q, want it unchanged on error", obj.GetAPIVersion())
+	}
+}
@@ -3,22 +3,203 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
 )
 
 // Manifest represents a single Kubernetes object serialized to YAML.
 type Manifest struct {
+	Kind     string
 	Filename string
 	Content  []byte
 }
 
+// ExportOptions controls which objects ExportNamespace reads.
+type ExportOptions struct {
+	// BestEffort records resource types that cannot be listed as
+	// ExportFailures instead of aborting the export.
+	BestEffort bool
+	// LabelSelector restricts the export to objects with matching labels.
+	LabelSelector string
+}
+
+// ExportFailure records a resource type that could not be exported.
+type ExportFailure struct {
+	Kind string
+	Err  error
+}
+
+// exporter lists the objects of one resource type in a namespace.
+type exporter struct {
+	gvk    schema.GroupVersionKind
+	plural string
+	list   func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error)
+}
+
+// exporters are the resource types included in backups, in export order.
+var exporters = []exporter{
+	{
+		gvk:    schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		plural: "ConfigMaps",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Version: "v1", Kind: "Secret"},
+		plural: "Secrets",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.CoreV1().Secrets(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+		plural: "Services",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.CoreV1().Services(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		plural: "Deployments",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		plural: "StatefulSets",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		plural: "DaemonSets",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		plural: "Jobs",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+		plural: "CronJobs",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		plural: "PersistentVolumeClaims",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+	{
+		gvk:    schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+		plural: "Ingresses",
+		list: func(ctx context.Context, c *Client, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			objs := make([]runtime.Object, 0, len(list.Items))
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+	},
+}
+
 // isSystemObject returns true for Kubernetes system-managed objects that
 // should not be included in user backups (for example kube-root-ca.crt).
-func isSystemObject(meta metav1.ObjectMeta) bool {
-	name := meta.Name
+func isSystemObject(meta metav1.Object) bool {
+	name := meta.GetName()
 
 	// Well-known auto-created ConfigMap present in every namespace.
 	if name == "kube-root-ca.crt" || strings.HasPrefix(name, "kube-root-ca.") {
@@ -26,7 +207,7 @@ func isSystemObject(meta metav1.ObjectMeta) bool {
 	}
 
 	// Skip objects from purely system namespaces.
-	switch meta.Namespace {
+	switch meta.GetNamespace() {
 	case "kube-system", "kube-public", "kube-node-lease":
 		return true
 	}
@@ -34,220 +215,70 @@ func isSystemObject(meta metav1.ObjectMeta) bool {
 	return false
 }
 
-// ExportNamespaceManifests returns YAML manifests for supported resources in the namespace.
-// Each resource is encoded as a separate YAML document.
-func (c *Client) ExportNamespaceManifests(ctx context.Context, namespace string) ([]Manifest, error) {
-	var manifests []Manifest
-
-	// ConfigMaps
-	configMaps, err := c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+// listType lists the objects of e's resource type in namespace, retrying
+// transient errors, and logs the count and the time the request took.
+func (c *Client) listType(ctx context.Context, e exporter, namespace string, opts metav1.ListOptions) ([]runtime.Object, error) {
+	start := time.Now()
+	var objs []runtime.Object
+	err := c.retry(ctx, "list "+e.plural, func() error {
+		var err error
+		objs, err = e.list(ctx, c, namespace, opts)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list ConfigMaps: %w", err)
-	}
-	for _, cm := range configMaps.Items {
-		if isSystemObject(cm.ObjectMeta) {
-			continue
-		}
-		cm.APIVersion = "v1"
-		cm.Kind = "ConfigMap"
-		data, err := yaml.Marshal(cm)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal ConfigMap %s: %w", cm.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("configmap-%s.yaml", cm.Name),
-			Content:  data,
-		})
-	}
-
-	// Secrets
-	secrets, err := c.Clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list Secrets: %w", err)
-	}
-	for _, secret := range secrets.Items {
-		if isSystemObject(secret.ObjectMeta) {
-			continue
-		}
-		secret.APIVersion = "v1"
-		secret.Kind = "Secret"
-		data, err := yaml.Marshal(secret)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal Secret %s: %w", secret.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("secret-%s.yaml", secret.Name),
-			Content:  data,
-		})
-	}
-
-	// Services
-	services, err := c.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list Services: %w", err)
-	}
-	for _, svc := range services.Items {
-		if isSystemObject(svc.ObjectMeta) {
-			continue
-		}
-		svc.APIVersion = "v1"
-		svc.Kind = "Service"
-		data, err := yaml.Marshal(svc)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal Service %s: %w", svc.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("service-%s.yaml", svc.Name),
-			Content:  data,
-		})
-	}
-
-	// Deployments
-	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list Deployments: %w", err)
-	}
-	for _, deploy := range deployments.Items {
-		if isSystemObject(deploy.ObjectMeta) {
-			continue
-		}
-		deploy.APIVersion = "apps/v1"
-		deploy.Kind = "Deployment"
-		data, err := yaml.Marshal(deploy)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal Deployment %s: %w", deploy.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("deployment-%s.yaml", deploy.Name),
-			Content:  data,
-		})
-	}
-
-	// StatefulSets
-	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list StatefulSets: %w", err)
-	}
-	for _, sts := range statefulSets.Items {
-		if isSystemObject(sts.ObjectMeta) {
-			continue
-		}
-		sts.APIVersion = "apps/v1"
-		sts.Kind = "StatefulSet"
-		data, err := yaml.Marshal(sts)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal StatefulSet %s: %w", sts.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("statefulset-%s.yaml", sts.Name),
-			Content:  data,
-		})
+		return nil, fmt.Errorf("failed to list %s: %w", e.plural, err)
 	}
+	slog.DebugContext(ctx, "listed resource type",
+		"kind", e.gvk.Kind, "namespace", namespace, "count", len(objs), "duration", time.Since(start))
+	return objs, nil
+}
 
-	// DaemonSets
-	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list DaemonSets: %w", err)
-	}
-	for _, ds := range daemonSets.Items {
-		if isSystemObject(ds.ObjectMeta) {
-			continue
-		}
-		ds.APIVersion = "apps/v1"
-		ds.Kind = "DaemonSet"
-		data, err := yaml.Marshal(ds)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal DaemonSet %s: %w", ds.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("daemonset-%s.yaml", ds.Name),
-			Content:  data,
-		})
-	}
+// ExportNamespaceManifests returns YAML manifests for supported resources in the namespace.
+// Each resource is encoded as a separate YAML document.
+func (c *Client) ExportNamespaceManifests(ctx context.Context, namespace string) ([]Manifest, error) {
+	manifests, _, err := c.ExportNamespace(ctx, namespace, ExportOptions{})
+	return manifests, err
+}
 
-	// Jobs
-	jobs, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list Jobs: %w", err)
-	}
-	for _, job := range jobs.Items {
-		if isSystemObject(job.ObjectMeta) {
-			continue
-		}
-		job.APIVersion = "batch/v1"
-		job.Kind = "Job"
-		data, err := yaml.Marshal(job)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal Job %s: %w", job.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("job-%s.yaml", job.Name),
-			Content:  data,
-		})
-	}
+// ExportNamespace returns YAML manifests for supported resources in the
+// namespace. In strict mode the first resource type that cannot be listed
+// aborts the export; with opts.BestEffort it is recorded as an ExportFailure
+// and the remaining types are still exported.
+func (c *Client) ExportNamespace(ctx context.Context, namespace string, opts ExportOptions) ([]Manifest, []ExportFailure, error) {
+	var (
+		manifests []Manifest
+		failures  []ExportFailure
+	)
 
-	// CronJobs
-	cronJobs, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list CronJobs: %w", err)
-	}
-	for _, cj := range cronJobs.Items {
-		if isSystemObject(cj.ObjectMeta) {
-			continue
-		}
-		cj.APIVersion = "batch/v1"
-		cj.Kind = "CronJob"
-		data, err := yaml.Marshal(cj)
+	for _, e := range exporters {
+		objs, err := c.listType(ctx, e, namespace, metav1.ListOptions{LabelSelector: opts.LabelSelector})
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal CronJob %s: %w", cj.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("cronjob-%s.yaml", cj.Name),
-			Content:  data,
-		})
-	}
-
-	// PersistentVolumeClaims
-	pvcs, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list PersistentVolumeClaims: %w", err)
-	}
-	for _, pvc := range pvcs.Items {
-		if isSystemObject(pvc.ObjectMeta) {
+			// An interrupted export is never recorded as a partial one.
+			if !opts.BestEffort || ctx.Err() != nil {
+				return nil, nil, err
+			}
+			slog.InfoContext(ctx, "skipping unreadable resource type", "kind", e.gvk.Kind, "namespace", namespace, "error", err)
+			failures = append(failures, ExportFailure{Kind: e.gvk.Kind, Err: err})
 			continue
 		}
-		pvc.APIVersion = "v1"
-		pvc.Kind = "PersistentVolumeClaim"
-		data, err := yaml.Marshal(pvc)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal PersistentVolumeClaim %s: %w", pvc.Name, err)
-		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("pvc-%s.yaml", pvc.Name),
-			Content:  data,
-		})
-	}
 
-	// Ingresses
-	ingresses, err := c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list Ingresses: %w", err)
-	}
-	for _, ing := range ingresses.Items {
-		if isSystemObject(ing.ObjectMeta) {
-			continue
-		}
-		ing.APIVersion = "networking.k8s.io/v1"
-		ing.Kind = "Ingress"
-		data, err := yaml.Marshal(ing)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal Ingress %s: %w", ing.Name, err)
+		for _, obj := range objs {
+			meta, ok := obj.(metav1.Object)
+			if !ok || isSystemObject(meta) {
+				continue
+			}
+			obj.GetObjectKind().SetGroupVersionKind(e.gvk)
+			data, err := yaml.Marshal(obj)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to marshal %s %s: %w", e.gvk.Kind, meta.GetName(), err)
+			}
+			manifests = append(manifests, Manifest{
+				Kind:     e.gvk.Kind,
+				Filename: ManifestFilename(e.gvk.Kind, meta.GetName()),
+				Content:  data,
+			})
 		}
-		manifests = append(manifests, Manifest{
-			Filename: fmt.Sprintf("ingress-%s.yaml", ing.Name),
-			Content:  data,
-		})
 	}
 
-	return manifests, nil
+	return manifests, failures, nil
 }
@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Factory builds Kubernetes clients from the kubectl connection flags
+// (--kubeconfig, --context, --cluster, --user, --token, --as, --server, ...)
+// shared by every command.
+type Factory struct {
+	configFlags *genericclioptions.ConfigFlags
+	kubeconfig  string
+	qps         float32
+	burst       int
+	retry       RetryOptions
+}
+
+// NewFactory returns a Factory with default flag values.
+func NewFactory() *Factory {
+	flags := genericclioptions.NewConfigFlags(false)
+	// Commands define their own --namespace, and the discovery cache is not used.
+	flags.Namespace = nil
+	flags.CacheDir = nil
+
+	return &Factory{configFlags: flags, retry: DefaultRetryOptions}
+}
+
+// AddFlags registers the connection flags on flags.
+func (f *Factory) AddFlags(flags *pflag.FlagSet) {
+	// Register --kubeconfig ourselves to keep its -k shorthand.
+	f.configFlags.KubeConfig = nil
+	f.configFlags.AddFlags(flags)
+	f.configFlags.KubeConfig = &f.kubeconfig
+	flags.StringVarP(&f.kubeconfig, "kubeconfig", "k", "", "Path to kubeconfig file (default: auto-detect)")
+
+	flags.Float32Var(&f.qps, "qps", 0, "Maximum queries per second to the Kubernetes API (default: client-go default)")
+	flags.IntVar(&f.burst, "burst", 0, "Maximum burst of queries to the Kubernetes API (default: client-go default)")
+
+	flags.IntVar(&f.retry.MaxRetries, "max-retries", f.retry.MaxRetries,
+		"Retries of list and apply calls that fail with a transient error (throttling, timeouts, 5xx, conflicts, dropped connections); 0 disables them")
+	flags.DurationVar(&f.retry.Backoff, "retry-backoff", f.retry.Backoff,
+		"Delay before the first retry; it doubles with every retry and is jittered. Responses with Retry-After are retried by the Kubernetes client itself")
+	flags.DurationVar(&f.retry.MaxBackoff, "retry-max-backoff", f.retry.MaxBackoff, "Maximum delay between retries")
+}
+
+// RESTConfig returns the REST config selected by the flags. When no
+// kubeconfig can be found it falls back to the in-cluster config, so the
+// commands work unchanged inside a pod.
+func (f *Factory) RESTConfig() (*rest.Config, error) {
+	config, err := f.configFlags.ToRESTConfig()
+	if err != nil && clientcmd.IsEmptyConfig(err) {
+		if inCluster, inClusterErr := rest.InClusterConfig(); inClusterErr == nil {
+			config, err = inCluster, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("build Kubernetes config: %w", err)
+	}
+
+	if f.qps > 0 {
+		config.QPS = f.qps
+	}
+	if f.burst > 0 {
+		config.Burst = f.burst
+	}
+
+	return config, nil
+}
+
+// ForContext returns a Factory for the kubeconfig context name. It shares the
+// kubeconfig file, request timeout, rate limits and retries of f but none of
+// the cluster or credential overrides, which belong to the current context.
+func (f *Factory) ForContext(name string) *Factory {
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.Namespace = nil
+	flags.CacheDir = nil
+	flags.Context = &name
+	flags.Timeout = f.configFlags.Timeout
+	flags.DisableCompression = f.configFlags.DisableCompression
+
+	ctxFactory := &Factory{
+		configFlags: flags,
+		kubeconfig:  f.kubeconfig,
+		qps:         f.qps,
+		burst:       f.burst,
+		retry:       f.retry,
+	}
+	flags.KubeConfig = &ctxFactory.kubeconfig
+	return ctxFactory
+}
+
+// Client returns a client for the cluster selected by the flags.
+func (f *Factory) Client() (*Client, error) {
+	config, err := f.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client.Retry = f.retry
+	return client, nil
+}
@@ -0,0 +1,65 @@
+// Package k8stest provides a k8s.Client backed by in-memory fake clients for
+// tests of code that lists, applies and deletes objects.
+package k8stest
+
+import (
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// Resources are the namespaced resources the fake cluster serves.
+var Resources = []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "secrets", Kind: "Secret", Namespaced: true},
+			{Name: "services", Kind: "Service", Namespaced: true},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true},
+			{Name: "daemonsets", Kind: "DaemonSet", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "jobs", Kind: "Job", Namespaced: true},
+			{Name: "cronjobs", Kind: "CronJob", Namespaced: true},
+		},
+	},
+}
+
+// NewClient returns a client for a fake cluster that serves Resources and
+// holds objs. Its Clientset is nil. The fake dynamic client is returned as
+// well so tests can add reactors to it.
+func NewClient(objs ...runtime.Object) (*k8s.Client, *dynamicfake.FakeDynamicClient) {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, list := range Resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			panic(err)
+		}
+		for _, r := range list.APIResources {
+			listKinds[gv.WithResource(r.Name)] = r.Kind + "List"
+		}
+	}
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+
+	disco := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: Resources}}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco))
+
+	return &k8s.Client{Dynamic: dynamic, Mapper: mapper}, dynamic
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// kindAliases maps lower-case kind names, plurals and kubectl short names to
+// the Kind of the resources handled by the exporter.
+var kindAliases = map[string]string{
+	"configmap":              "ConfigMap",
+	"configmaps":             "ConfigMap",
+	"cm":                     "ConfigMap",
+	"secret":                 "Secret",
+	"secrets":                "Secret",
+	"service":                "Service",
+	"services":               "Service",
+	"svc":                    "Service",
+	"deployment":             "Deployment",
+	"deployments":            "Deployment",
+	"deploy":                 "Deployment",
+	"statefulset":            "StatefulSet",
+	"statefulsets":           "StatefulSet",
+	"sts":                    "StatefulSet",
+	"daemonset":              "DaemonSet",
+	"daemonsets":             "DaemonSet",
+	"ds":                     "DaemonSet",
+	"job":                    "Job",
+	"jobs":                   "Job",
+	"cronjob":                "CronJob",
+	"cronjobs":               "CronJob",
+	"cj":                     "CronJob",
+	"persistentvolumeclaim":  "PersistentVolumeClaim",
+	"persistentvolumeclaims": "PersistentVolumeClaim",
+	"pvc":                    "PersistentVolumeClaim",
+	"ingress":                "Ingress",
+	"ingresses":              "Ingress",
+	"ing":                    "Ingress",
+}
+
+// ObjectRef identifies an object by kind and name, as in "deployment/my-app".
+type ObjectRef struct {
+	Kind string
+	Name string
+}
+
+// String returns the reference in kind/name form.
+func (r ObjectRef) String() string {
+	return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+}
+
+// ParseObjectRef parses a kind/name reference. The kind may be given in any
+// case, in plural form or as a kubectl short name.
+func ParseObjectRef(s string) (ObjectRef, error) {
+	kind, name, ok := strings.Cut(s, "/")
+	if !ok || kind == "" || name == "" || strings.Contains(name, "/") {
+		return ObjectRef{}, fmt.Errorf("invalid object reference %q, expected kind/name", s)
+	}
+
+	return ObjectRef{Kind: NormalizeKind(kind), Name: name}, nil
+}
+
+// NormalizeKind returns the Kind for a lower-case, plural or short kind name
+// of a resource handled by the exporter. Unknown names are returned as is.
+func NormalizeKind(kind string) string {
+	if k, ok := kindAliases[strings.ToLower(kind)]; ok {
+		return k
+	}
+	return kind
+}
+
+// Matches reports whether obj has the referenced kind and name.
+func (r ObjectRef) Matches(obj *unstructured.Unstructured) bool {
+	return strings.EqualFold(obj.GetKind(), r.Kind) && obj.GetName() == r.Name
+}
+
+// DecodeManifest decodes a single YAML manifest into an unstructured object.
+func DecodeManifest(manifest []byte) (*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("convert YAML to JSON: %w", err)
+	}
+
+	var obj unstructured.Unstructured
+	if err := json.Unmarshal(jsonData, &obj); err != nil {
+		return nil, fmt.Errorf("unmarshal JSON into unstructured object: %w", err)
+	}
+
+	return &obj, nil
+}
+
+// ResourceInfoFromObject returns the listing information for obj.
+func ResourceInfoFromObject(obj *unstructured.Unstructured) ResourceInfo {
+	return ResourceInfo{
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		APIVersion: obj.GetAPIVersion(),
+		Labels:     obj.GetLabels(),
+	}
+}
+
+// serverMetadataFields are metadata fields owned by the API server.
+var serverMetadataFields = []string{
+	"uid",
+	"resourceVersion",
+	"generation",
+	"creationTimestamp",
+	"deletionTimestamp",
+	"deletionGracePeriodSeconds",
+	"managedFields",
+	"selfLink",
+}
+
+// ClearServerFields removes the status and the metadata fields owned by the
+// API server, so that a copy of a live object can be applied again.
+func ClearServerFields(obj *unstructured.Unstructured) {
+	for _, field := range serverMetadataFields {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+}
+
+// ManifestFilename returns the archive file name used for an object, e.g.
+// deployment-my-app.yaml.
+func ManifestFilename(kind, name string) string {
+	prefix := strings.ToLower(kind)
+	if kind == "PersistentVolumeClaim" {
+		prefix = "pvc"
+	}
+	return fmt.Sprintf("%s-%s.yaml", prefix, name)
+}
@@ -2,73 +2,323 @@ package k8s
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/restmapper"
-	"sigs.k8s.io/yaml"
 )
 
+// ExistingPolicy decides what ApplyObject does with an object that already
+// exists in the cluster.
+type ExistingPolicy string
+
+const (
+	// ExistingSkip leaves the live object untouched.
+	ExistingSkip ExistingPolicy = "skip"
+	// ExistingUpdate overwrites the live object.
+	ExistingUpdate ExistingPolicy = "update"
+	// ExistingFail aborts with an error.
+	ExistingFail ExistingPolicy = "fail"
+	// ExistingRecreate overwrites the live object, deleting and re-creating
+	// it when the update is rejected because of immutable fields.
+	ExistingRecreate ExistingPolicy = "recreate"
+)
+
+// ParseExistingPolicy parses the name of an ExistingPolicy.
+func ParseExistingPolicy(s string) (ExistingPolicy, error) {
+	switch p := ExistingPolicy(s); p {
+	case ExistingSkip, ExistingUpdate, ExistingFail, ExistingRecreate:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid existing object policy %q, expected skip, update, fail or recreate", s)
+}
+
+// ApplyAction describes what ApplyObject did with an object.
+type ApplyAction string
+
+const (
+	ActionCreated   ApplyAction = "created"
+	ActionUpdated   ApplyAction = "updated"
+	ActionSkipped   ApplyAction = "skipped"
+	ActionRecreated ApplyAction = "recreated"
+	ActionDeleted   ApplyAction = "deleted"
+)
+
+// deleteTimeout bounds how long a recreate waits for the old object to go away.
+const deleteTimeout = 2 * time.Minute
+
 // ApplyYAML applies a single Kubernetes manifest to the cluster.
 // If the resource already exists, it will be updated.
-func (c *Client) ApplyYAML(ctx context.Context, mapper *restmapper.DeferredDiscoveryRESTMapper, dyn dynamic.Interface, namespaceFallback string, manifest []byte) error {
+func (c *Client) ApplyYAML(ctx context.Context, namespaceFallback string, manifest []byte) error {
 	if len(manifest) == 0 {
 		return nil
 	}
 
 	// Decode YAML into unstructured object.
-	jsonData, err := yaml.YAMLToJSON(manifest)
+	obj, err := DecodeManifest(manifest)
 	if err != nil {
-		return fmt.Errorf("convert YAML to JSON: %w", err)
+		return err
 	}
-
-	var obj unstructured.Unstructured
-	if err := json.Unmarshal(jsonData, &obj); err != nil {
-		return fmt.Errorf("unmarshal JSON into unstructured object: %w", err)
+	if _, _, err := ConvertDeprecated(obj); err != nil {
+		return err
 	}
 
-	gvk := obj.GroupVersionKind()
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	_, err = c.ApplyObject(ctx, obj, ApplyOptions{
+		NamespaceFallback: namespaceFallback,
+		Existing:          ExistingUpdate,
+	})
+	return err
+}
+
+// ApplyOptions controls how ApplyObject writes an object.
+type ApplyOptions struct {
+	// NamespaceFallback is used for namespaced objects without a namespace.
+	NamespaceFallback string
+	// Existing decides what happens if the object already exists.
+	Existing ExistingPolicy
+	// DryRun sends every write as a server-side dry run.
+	DryRun bool
+}
+
+// ApplyObject applies a decoded Kubernetes object to the cluster. If the
+// resource already exists, opts.Existing decides whether it is skipped,
+// updated, re-created or reported as an error. Each API call is retried on
+// transient errors, creates only if the error shows nothing was created; an
+// update conflict is retried with the object's current resourceVersion.
+func (c *Client) ApplyObject(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOptions) (ApplyAction, error) {
+	start := time.Now()
+	action, err := c.applyObject(ctx, obj, opts)
 	if err != nil {
-		return fmt.Errorf("find REST mapping for %s: %w", gvk.String(), err)
+		return "", err
 	}
+	slog.DebugContext(ctx, "applied object", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName(),
+		"action", action, "dry_run", opts.DryRun, "duration", time.Since(start))
+	return action, nil
+}
 
-	ns := obj.GetNamespace()
-	if ns == "" {
-		ns = namespaceFallback
+func (c *Client) applyObject(ctx context.Context, obj *unstructured.Unstructured, opts ApplyOptions) (ApplyAction, error) {
+	var dryRun []string
+	if opts.DryRun {
+		dryRun = []string{metav1.DryRunAll}
 	}
 
-	var resourceClient dynamic.ResourceInterface
-	if mapping.Scope.Name() == "namespace" {
-		resourceClient = dyn.Resource(mapping.Resource).Namespace(ns)
-	} else {
-		resourceClient = dyn.Resource(mapping.Resource)
+	gvk := obj.GroupVersionKind()
+	resourceClient, err := c.resourceClientFor(obj, opts.NamespaceFallback)
+	if err != nil {
+		return "", err
+	}
+	ref := gvk.Kind + "/" + obj.GetName()
+	create := func(opts metav1.CreateOptions) error {
+		return c.retryCreate(ctx, "create "+ref, func() error {
+			_, err := resourceClient.Create(ctx, obj, opts)
+			return err
+		})
 	}
 
-	// Try to create, fall back to update if already exists.
+	// Try to create, fall back to opts.Existing if it already exists.
 	// For create, resourceVersion must be empty.
 	obj.SetResourceVersion("")
-	_, err = resourceClient.Create(ctx, &obj, metav1.CreateOptions{})
-	if apierrors.IsAlreadyExists(err) {
-		// Need current resource version for update.
-		existing, getErr := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	err = create(metav1.CreateOptions{DryRun: dryRun})
+	if err == nil {
+		return ActionCreated, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("create %s/%s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	switch opts.Existing {
+	case ExistingSkip:
+		return ActionSkipped, nil
+	case ExistingFail:
+		return "", fmt.Errorf("%s/%s already exists", gvk.Kind, obj.GetName())
+	}
+
+	// Need current resource version for update. A conflict means the object
+	// changed in between, so the retry fetches it again.
+	var existing *unstructured.Unstructured
+	var getErr error
+	err = c.retry(ctx, "update "+ref, func() error {
+		existing, getErr = resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
 		if getErr != nil {
-			return fmt.Errorf("get existing %s/%s: %w", gvk.Kind, obj.GetName(), getErr)
+			return getErr
 		}
 		obj.SetResourceVersion(existing.GetResourceVersion())
-		if _, err := resourceClient.Update(ctx, &obj, metav1.UpdateOptions{}); err != nil {
-			return fmt.Errorf("update %s/%s: %w", gvk.Kind, obj.GetName(), err)
+		_, err := resourceClient.Update(ctx, obj, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	})
+	if getErr != nil {
+		return "", fmt.Errorf("get existing %s/%s: %w", gvk.Kind, obj.GetName(), getErr)
+	}
+	if err == nil {
+		return ActionUpdated, nil
+	}
+	if opts.Existing != ExistingRecreate || !apierrors.IsInvalid(err) {
+		return "", fmt.Errorf("update %s/%s: %w", gvk.Kind, obj.GetName(), err)
+	}
+	if !isImmutableFieldError(err) {
+		// The object may be invalid for another reason, in which case the
+		// create after the delete would fail too and lose the live object.
+		// Validate it as a new object first; AlreadyExists is only returned
+		// once validation has passed.
+		obj.SetResourceVersion("")
+		createErr := create(metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		if createErr != nil && !apierrors.IsAlreadyExists(createErr) {
+			return "", fmt.Errorf("update %s/%s: %w", gvk.Kind, obj.GetName(), err)
 		}
-		return nil
+	}
+	if opts.DryRun {
+		// A dry-run delete leaves the object in place, so the create cannot be simulated.
+		return ActionRecreated, nil
 	}
 
+	// The update touched immutable fields; replace the object instead.
+	err = c.retry(ctx, "delete "+ref, func() error {
+		return deleteAndWait(ctx, resourceClient, existing)
+	})
 	if err != nil {
-		return fmt.Errorf("create %s/%s: %w", gvk.Kind, obj.GetName(), err)
+		return "", fmt.Errorf("delete %s/%s for recreate: %w", gvk.Kind, obj.GetName(), err)
 	}
+	obj.SetResourceVersion("")
+	if err := create(metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("recreate %s/%s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	return ActionRecreated, nil
+}
+
+// isImmutableFieldError reports whether an Invalid update error rejects
+// changes to immutable fields, which only a delete and create can apply.
+func isImmutableFieldError(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	causes := status.Status().Details.Causes
+	if len(causes) == 0 {
+		return false
+	}
+	for _, cause := range causes {
+		immutable := strings.Contains(cause.Message, "field is immutable")
+		forbidden := cause.Type == metav1.CauseType(field.ErrorTypeForbidden) && strings.HasPrefix(cause.Field, "spec")
+		if !immutable && !forbidden {
+			return false
+		}
+	}
+	return true
+}
 
+// GetLive returns the current state of obj in the cluster, or nil if it does
+// not exist. Namespaced objects without a namespace are looked up in
+// namespaceFallback.
+func (c *Client) GetLive(ctx context.Context, obj *unstructured.Unstructured, namespaceFallback string) (*unstructured.Unstructured, error) {
+	resourceClient, err := c.resourceClientFor(obj, namespaceFallback)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return live, nil
+}
+
+// DeleteObject deletes obj from the cluster and waits until it is gone.
+// Deleting an object that does not exist is not an error.
+func (c *Client) DeleteObject(ctx context.Context, obj *unstructured.Unstructured) error {
+	resourceClient, err := c.resourceClientFor(obj, "")
+	if err != nil {
+		return err
+	}
+
+	live, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	if err := deleteAndWait(ctx, resourceClient, live); err != nil {
+		return fmt.Errorf("delete %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	slog.DebugContext(ctx, "deleted object", "kind", obj.GetKind(), "namespace", live.GetNamespace(), "name", obj.GetName())
 	return nil
 }
+
+// ListObjects returns the live objects of the given kind in namespace.
+func (c *Client) ListObjects(ctx context.Context, gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error) {
+	mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("find REST mapping for %s: %w", gvk.String(), err)
+	}
+
+	var resourceClient dynamic.ResourceInterface = c.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == "namespace" {
+		resourceClient = c.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	var list *unstructured.UnstructuredList
+	err = c.retry(ctx, "list "+mapping.Resource.Resource, func() error {
+		list, err = resourceClient.List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", mapping.Resource.Resource, err)
+	}
+	return list.Items, nil
+}
+
+// resourceClientFor returns the dynamic client for obj's resource. Namespaced
+// objects without a namespace are placed in namespaceFallback.
+func (c *Client) resourceClientFor(obj *unstructured.Unstructured, namespaceFallback string) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("find REST mapping for %s: %w", gvk.String(), err)
+	}
+
+	if mapping.Scope.Name() != "namespace" {
+		return c.Dynamic.Resource(mapping.Resource), nil
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespaceFallback)
+	}
+	return c.Dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// deleteAndWait deletes obj and waits until it is gone from the API.
+func deleteAndWait(ctx context.Context, resourceClient dynamic.ResourceInterface, obj *unstructured.Unstructured) error {
+	propagation := metav1.DeletePropagationBackground
+	uid := obj.GetUID()
+	err := resourceClient.Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, deleteTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// A different UID means the object was already re-created by someone else.
+		return current.GetUID() != uid, nil
+	})
+}
@@ -0,0 +1,115 @@
+package k8s_test
+
+import (
+	"context"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s/k8stest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var configMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func newConfigMap() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("shop")
+	obj.SetName("settings")
+	return obj
+}
+
+func TestApplyObjectRetriesCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		// err fails the first create; persisted means the object was
+		// stored before the error.
+		err       error
+		persisted bool
+		want      k8s.ApplyAction
+		wantErr   bool
+		creates   int
+	}{
+		{
+			name:    "throttled",
+			err:     apierrors.NewTooManyRequests("slow down", 0),
+			want:    k8s.ActionCreated,
+			creates: 2,
+		},
+		{
+			name:    "leader changed",
+			err:     apierrors.NewInternalError(io.ErrClosedPipe),
+			want:    k8s.ActionCreated,
+			creates: 2,
+		},
+		{
+			name:      "gateway timeout after persisting",
+			err:       apierrors.NewTimeoutError("request timed out", 0),
+			persisted: true,
+			wantErr:   true,
+			creates:   1,
+		},
+		{
+			name:      "connection reset after persisting",
+			err:       syscall.ECONNRESET,
+			persisted: true,
+			wantErr:   true,
+			creates:   1,
+		},
+		{
+			name:      "EOF after persisting",
+			err:       io.ErrUnexpectedEOF,
+			persisted: true,
+			wantErr:   true,
+			creates:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, dynamic := k8stest.NewClient()
+			client.Retry = k8s.RetryOptions{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+			creates := 0
+			dynamic.PrependReactor("create", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				creates++
+				if creates > 1 {
+					return false, nil, nil
+				}
+				if tt.persisted {
+					obj := action.(clienttesting.CreateAction).GetObject()
+					if err := dynamic.Tracker().Create(configMapsResource, obj, "shop"); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return true, nil, tt.err
+			})
+
+			action, err := client.ApplyObject(context.Background(), newConfigMap(), k8s.ApplyOptions{Existing: k8s.ExistingSkip})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ApplyObject = %s, want an error", action)
+				}
+			} else if err != nil {
+				t.Fatalf("ApplyObject: %v", err)
+			} else if action != tt.want {
+				t.Errorf("action = %s, want %s", action, tt.want)
+			}
+			if creates != tt.creates {
+				t.Errorf("creates = %d, want %d", creates, tt.creates)
+			}
+
+			if _, err := dynamic.Resource(configMapsResource).Namespace("shop").Get(context.Background(), "settings", metav1.GetOptions{}); err != nil {
+				t.Errorf("object not created: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package k8s
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/morheus9/k8s-backup-cli/internal/metrics"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// client-go accepts a single registration of its request hooks, so the
+// metrics and the retry log are registered together here.
+func init() {
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestResult: metrics.RequestResult,
+		RequestRetry:  retryLogger{},
+	})
+}
+
+// retryLogger logs the requests client-go retries, for example after a 429
+// with Retry-After.
+type retryLogger struct{}
+
+func (retryLogger) IncrementRetry(ctx context.Context, code, method, host string) {
+	slog.WarnContext(ctx, "retrying Kubernetes API request", "method", method, "code", code, "host", host)
+}
@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// RetryOptions controls how API calls that fail with a transient error are
+// retried.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// disables retries.
+	MaxRetries int
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry, up to MaxBackoff, and is jittered by up to 50%.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryOptions are the retry settings of the command line flags.
+var DefaultRetryOptions = RetryOptions{
+	MaxRetries: 4,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// transient, the retries are used up or ctx is done. op names the call in
+// the log.
+//
+// client-go's REST client already retries responses that carry a
+// Retry-After header (429 and some 5xx) up to 10 times, waiting as the
+// server asks. Such errors have used up those retries and are returned
+// as-is rather than multiplying them; retry covers the transient errors
+// client-go gives up on at once.
+func (c *Client) retry(ctx context.Context, op string, fn func() error) error {
+	return c.retryIf(ctx, op, IsTransient, fn)
+}
+
+// retryCreate is retry for creates, which are not idempotent: errors that
+// leave open whether the object was created are not retried, as the retry
+// would find the object of the first attempt and report it as existing.
+func (c *Client) retryCreate(ctx context.Context, op string, fn func() error) error {
+	return c.retryIf(ctx, op, func(err error) bool {
+		return IsTransient(err) && !isAmbiguous(err)
+	}, fn)
+}
+
+// retryIf is retry with transient deciding which errors are retried.
+func (c *Client) retryIf(ctx context.Context, op string, transient func(error) bool, fn func() error) error {
+	backoff := c.Retry.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.Retry.MaxRetries || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+		if _, ok := apierrors.SuggestsClientDelay(err); ok {
+			return err
+		}
+
+		delay := backoff/2 + rand.N(backoff/2+1)
+		slog.WarnContext(ctx, "retrying after transient error",
+			"operation", op, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, c.Retry.MaxBackoff)
+	}
+}
+
+// IsTransient reports whether err is worth retrying: throttling, server-side
+// and client-side timeouts, 5xx responses (e.g. "etcdserver: leader
+// changed"), update conflicts and dropped connections.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch {
+	case apierrors.IsTooManyRequests(err),
+		apierrors.IsServerTimeout(err),
+		apierrors.IsTimeout(err),
+		apierrors.IsInternalError(err),
+		apierrors.IsServiceUnavailable(err),
+		apierrors.IsUnexpectedServerError(err),
+		apierrors.IsConflict(err):
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= 500
+	}
+
+	if utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isAmbiguous reports whether err leaves open whether the server carried out
+// the request: the connection broke or timed out after the request was sent,
+// or the server gave up waiting for the outcome.
+func isAmbiguous(err error) bool {
+	if apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) {
+		return true
+	}
+	if utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,198 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyOrder lists kinds in the order their objects are applied, so that
+// configuration and storage exist before the workloads that reference them.
+// Kinds not listed are applied last.
+var applyOrder = []string{
+	"Namespace",
+	"ConfigMap",
+	"Secret",
+	"PersistentVolumeClaim",
+	"Service",
+	"Deployment",
+	"StatefulSet",
+	"DaemonSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+}
+
+// bindAnnotations are set on PersistentVolumeClaims by the PV controller and
+// tie a claim to a volume of the cluster it was read from.
+var bindAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// jobControllerLabels are added to Jobs and their pod templates by the Job
+// controller and contain the UID of the original Job.
+var jobControllerLabels = []string{
+	"controller-uid",
+	"batch.kubernetes.io/controller-uid",
+}
+
+// SortForApply orders objs by kind following applyOrder. Objects of the same
+// kind keep their relative order.
+func SortForApply(objs []*unstructured.Unstructured) {
+	rank := func(kind string) int {
+		for i, k := range applyOrder {
+			if k == kind {
+				return i
+			}
+		}
+		return len(applyOrder)
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rank(objs[i].GetKind()) < rank(objs[j].GetKind())
+	})
+}
+
+// Sanitize prepares an object read from one place to be created somewhere
+// else: besides the server-populated fields it removes owner references,
+// which refer to objects by their UID in the source, Service cluster IPs and
+// node ports, PersistentVolumeClaim volume bindings and the generated Job
+// selector. Objects owned by a controller should not be copied at all.
+func Sanitize(obj *unstructured.Unstructured) {
+	ClearServerFields(obj)
+	obj.SetOwnerReferences(nil)
+
+	switch obj.GetKind() {
+	case "Service":
+		// Headless Services keep clusterIP: None.
+		if ip, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); ip != corev1.ClusterIPNone {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		}
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		unstructured.RemoveNestedField(obj.Object, "spec", "healthCheckNodePort")
+		if ports, ok, _ := unstructured.NestedSlice(obj.Object, "spec", "ports"); ok {
+			for _, p := range ports {
+				if port, ok := p.(map[string]interface{}); ok {
+					delete(port, "nodePort")
+				}
+			}
+			_ = unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+		}
+
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+		removeAnnotations(obj, bindAnnotations)
+
+	case "Job":
+		unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+		removeLabels(obj.Object, jobControllerLabels, "metadata", "labels")
+		removeLabels(obj.Object, jobControllerLabels, "spec", "template", "metadata", "labels")
+	}
+}
+
+// MapNamespace moves obj from namespace from to namespace to. Besides
+// metadata.namespace it rewrites references to the old namespace inside the
+// object: fields named "namespace" and in-cluster DNS names such as
+// db.from.svc.cluster.local. Secret data is left untouched.
+func MapNamespace(obj *unstructured.Unstructured, from, to string) {
+	obj.SetNamespace(to)
+	if from == "" || from == to {
+		return
+	}
+
+	for key, value := range obj.Object {
+		if key == "metadata" || (obj.GetKind() == "Secret" && key == "data") {
+			continue
+		}
+		obj.Object[key] = mapNamespaceRefs(value, from, to)
+	}
+
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		// The previous configuration belongs to the source namespace.
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+func mapNamespaceRefs(value interface{}, from, to string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if s, ok := field.(string); ok && key == "namespace" && s == from {
+				v[key] = to
+				continue
+			}
+			v[key] = mapNamespaceRefs(field, from, to)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = mapNamespaceRefs(v[i], from, to)
+		}
+		return v
+	case string:
+		return strings.ReplaceAll(v, "."+from+".svc", "."+to+".svc")
+	}
+	return value
+}
+
+// EnsureNamespace creates the namespace name if it does not exist and
+// reports whether it had to be created.
+func (c *Client) EnsureNamespace(ctx context.Context, name string, dryRun bool) (bool, error) {
+	_, err := c.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("get namespace %s: %w", name, err)
+	}
+
+	opts := metav1.CreateOptions{}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if _, err := c.Clientset.CoreV1().Namespaces().Create(ctx, ns, opts); err != nil && !apierrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("create namespace %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func removeAnnotations(obj *unstructured.Unstructured, keys []string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	for _, key := range keys {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+}
+
+func removeLabels(obj map[string]interface{}, keys []string, fields ...string) {
+	labels, ok, _ := unstructured.NestedStringMap(obj, fields...)
+	if !ok {
+		return
+	}
+	for _, key := range keys {
+		delete(labels, key)
+	}
+	if len(labels) == 0 {
+		unstructured.RemoveNestedField(obj, fields...)
+		return
+	}
+	_ = unstructured.SetNestedStringMap(obj, labels, fields...)
+}
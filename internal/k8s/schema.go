@@ -0,0 +1,204 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// UnknownFieldPolicy decides what a restore does with fields the target
+// cluster's schema does not know.
+type UnknownFieldPolicy string
+
+const (
+	// UnknownFieldsWarn reports unknown fields and applies objects unchanged.
+	UnknownFieldsWarn UnknownFieldPolicy = "warn"
+	// UnknownFieldsDrop removes unknown fields before applying.
+	UnknownFieldsDrop UnknownFieldPolicy = "drop"
+	// UnknownFieldsIgnore skips the schema check.
+	UnknownFieldsIgnore UnknownFieldPolicy = "ignore"
+)
+
+// ParseUnknownFieldPolicy parses the name of an UnknownFieldPolicy.
+func ParseUnknownFieldPolicy(s string) (UnknownFieldPolicy, error) {
+	switch p := UnknownFieldPolicy(s); p {
+	case UnknownFieldsWarn, UnknownFieldsDrop, UnknownFieldsIgnore:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid unknown field policy %q, expected warn, drop or ignore", s)
+}
+
+const (
+	gvkExtension                   = "x-kubernetes-group-version-kind"
+	preserveUnknownFieldsExtension = "x-kubernetes-preserve-unknown-fields"
+	schemaRefPrefix                = "#/components/schemas/"
+)
+
+// SchemaChecker finds fields of objects that are not part of the target
+// cluster's OpenAPI v3 schema. Schemas are fetched once per group version.
+type SchemaChecker struct {
+	paths   map[string]openapi.GroupVersion
+	schemas map[string]map[string]*spec.Schema
+}
+
+// NewSchemaChecker returns a SchemaChecker for the cluster of c.
+func (c *Client) NewSchemaChecker() (*SchemaChecker, error) {
+	paths, err := c.Clientset.Discovery().OpenAPIV3().Paths()
+	if err != nil {
+		return nil, fmt.Errorf("fetch OpenAPI v3 paths: %w", err)
+	}
+	return &SchemaChecker{
+		paths:   paths,
+		schemas: make(map[string]map[string]*spec.Schema),
+	}, nil
+}
+
+// UnknownFields returns the paths of the fields of obj that the schema of its
+// kind does not define, e.g. spec.template.spec.containers[0].resizePolicy.
+// With drop the fields are removed from obj. Kinds without a published
+// schema are not checked.
+func (s *SchemaChecker) UnknownFields(obj *unstructured.Unstructured, drop bool) ([]string, error) {
+	gvk := obj.GroupVersionKind()
+	components, err := s.components(gvk.GroupVersion())
+	if err != nil || components == nil {
+		return nil, err
+	}
+
+	root := findKindSchema(components, gvk)
+	if root == nil {
+		return nil, nil
+	}
+
+	w := schemaWalker{components: components, drop: drop}
+	w.walk(obj.Object, root, "")
+	sort.Strings(w.unknown)
+	return w.unknown, nil
+}
+
+// components returns the schemas published for gv, or nil if the server
+// does not publish any.
+func (s *SchemaChecker) components(gv schema.GroupVersion) (map[string]*spec.Schema, error) {
+	path := "apis/" + gv.String()
+	if gv.Group == "" {
+		path = "api/" + gv.Version
+	}
+	if cached, ok := s.schemas[path]; ok {
+		return cached, nil
+	}
+
+	groupVersion, ok := s.paths[path]
+	if !ok {
+		s.schemas[path] = nil
+		return nil, nil
+	}
+	data, err := groupVersion.Schema("application/json")
+	if err != nil {
+		return nil, fmt.Errorf("fetch OpenAPI v3 schema for %s: %w", gv.String(), err)
+	}
+	var doc spec3.OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode OpenAPI v3 schema for %s: %w", gv.String(), err)
+	}
+
+	var components map[string]*spec.Schema
+	if doc.Components != nil {
+		components = doc.Components.Schemas
+	}
+	s.schemas[path] = components
+	return components, nil
+}
+
+// findKindSchema returns the schema tagged with gvk.
+func findKindSchema(components map[string]*spec.Schema, gvk schema.GroupVersionKind) *spec.Schema {
+	for _, sch := range components {
+		tags, ok := sch.Extensions[gvkExtension].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, t := range tags {
+			tag, ok := t.(map[string]interface{})
+			if ok && tag["group"] == gvk.Group && tag["version"] == gvk.Version && tag["kind"] == gvk.Kind {
+				return sch
+			}
+		}
+	}
+	return nil
+}
+
+// schemaWalker compares an object against a schema and collects the paths
+// of fields without a definition.
+type schemaWalker struct {
+	components map[string]*spec.Schema
+	drop       bool
+	unknown    []string
+}
+
+// resolve follows $ref and single-element allOf wrappers to the schema that
+// defines the fields.
+func (w *schemaWalker) resolve(sch *spec.Schema) *spec.Schema {
+	for sch != nil {
+		if ref := sch.Ref.String(); ref != "" {
+			sch = w.components[strings.TrimPrefix(ref, schemaRefPrefix)]
+			continue
+		}
+		if len(sch.AllOf) == 1 && len(sch.Properties) == 0 {
+			sch = &sch.AllOf[0]
+			continue
+		}
+		return sch
+	}
+	return nil
+}
+
+func (w *schemaWalker) walk(value interface{}, sch *spec.Schema, path string) {
+	sch = w.resolve(sch)
+	if sch == nil {
+		return
+	}
+	if preserve, _ := sch.Extensions[preserveUnknownFieldsExtension].(bool); preserve {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(sch.Properties) == 0 {
+			if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+				for key, field := range v {
+					w.walk(field, sch.AdditionalProperties.Schema, path+"["+key+"]")
+				}
+			}
+			// Free-form objects (no properties, no additionalProperties schema) accept anything.
+			return
+		}
+		for key, field := range v {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			prop, ok := sch.Properties[key]
+			if !ok {
+				w.unknown = append(w.unknown, fieldPath)
+				if w.drop {
+					delete(v, key)
+				}
+				continue
+			}
+			w.walk(field, &prop, fieldPath)
+		}
+
+	case []interface{}:
+		if sch.Items == nil || sch.Items.Schema == nil {
+			return
+		}
+		for i, item := range v {
+			w.walk(item, sch.Items.Schema, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// waitPollInterval is how often WaitForReady re-checks object status.
+const waitPollInterval = 2 * time.Second
+
+// eventsPerObject is how many recent events are reported for an unready object.
+const eventsPerObject = 3
+
+// ObjectKey identifies a namespaced object by kind, namespace and name.
+type ObjectKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String returns the key in Kind/namespace/name form.
+func (k ObjectKey) String() string {
+	return fmt.Sprintf("%s/%s/%s", k.Kind, k.Namespace, k.Name)
+}
+
+// IsWaitable reports whether WaitForReady knows how to check kind.
+func IsWaitable(kind string) bool {
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "Job", "PersistentVolumeClaim":
+		return true
+	}
+	return false
+}
+
+// UnreadyObject describes an object that did not become ready in time.
+type UnreadyObject struct {
+	Key    ObjectKey
+	Status string
+	Events []string
+}
+
+// WaitError is returned by WaitForReady when some objects did not become
+// ready, either because the timeout expired or because they failed.
+type WaitError struct {
+	Reason  string
+	Unready []UnreadyObject
+}
+
+func (e *WaitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d objects not ready", e.Reason, len(e.Unready))
+	for _, u := range e.Unready {
+		fmt.Fprintf(&b, "\n  %s: %s", u.Key, u.Status)
+		for _, ev := range u.Events {
+			fmt.Fprintf(&b, "\n    %s", ev)
+		}
+	}
+	return b.String()
+}
+
+// errObjectFailed marks a status that can no longer become ready by waiting.
+var errObjectFailed = errors.New("object failed")
+
+// WaitForReady polls keys until every Deployment, StatefulSet and DaemonSet
+// has rolled out, every Job has completed and every PersistentVolumeClaim is
+// bound. Keys of other kinds are ignored. Status changes are written to
+// progress. If timeout expires or an object fails, a *WaitError listing the
+// unready objects with their latest events is returned. A zero timeout waits
+// until the deadline of ctx, which then also results in a *WaitError.
+func (c *Client) WaitForReady(ctx context.Context, keys []ObjectKey, timeout time.Duration, progress io.Writer) error {
+	pending := make(map[ObjectKey]string)
+	for _, key := range keys {
+		if IsWaitable(key.Kind) {
+			pending[key] = "waiting"
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	parent := ctx
+	untilDeadline := timeout <= 0
+	if untilDeadline {
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline).Round(time.Second)
+		}
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	_, _ = fmt.Fprintf(progress, "Waiting up to %s for %d objects to become ready\n", timeout, len(pending))
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, key := range sortedKeys(pending) {
+			ready, status, err := c.objectStatus(ctx, key)
+			if errors.Is(err, errObjectFailed) {
+				return c.waitError(ctx, "object failed", map[ObjectKey]string{key: status})
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				status = fmt.Sprintf("error checking status: %v", err)
+			}
+
+			if ready {
+				_, _ = fmt.Fprintf(progress, "%s: ready\n", key)
+				delete(pending, key)
+				continue
+			}
+			if status != pending[key] {
+				_, _ = fmt.Fprintf(progress, "%s: %s\n", key, status)
+				pending[key] = status
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil && (!untilDeadline || !errors.Is(err, context.DeadlineExceeded)) {
+				// Interrupted or out of overall time, not a wait timeout.
+				return err
+			}
+			return c.waitError(context.Background(), fmt.Sprintf("timed out after %s", timeout), pending)
+		case <-ticker.C:
+		}
+	}
+}
+
+// objectStatus reports whether the object is ready and a short description of its state.
+func (c *Client) objectStatus(ctx context.Context, key ObjectKey) (bool, string, error) {
+	switch key.Kind {
+	case "Deployment":
+		d, err := c.Clientset.AppsV1().Deployments(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		return deploymentStatus(d)
+	case "StatefulSet":
+		sts, err := c.Clientset.AppsV1().StatefulSets(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		return statefulSetStatus(sts)
+	case "DaemonSet":
+		ds, err := c.Clientset.AppsV1().DaemonSets(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		return daemonSetStatus(ds)
+	case "Job":
+		job, err := c.Clientset.BatchV1().Jobs(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		return jobStatus(job)
+	case "PersistentVolumeClaim":
+		pvc, err := c.Clientset.CoreV1().PersistentVolumeClaims(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		if pvc.Status.Phase == corev1.ClaimBound {
+			return true, "bound", nil
+		}
+		if pvc.Status.Phase == corev1.ClaimLost {
+			return false, "claim lost its volume", errObjectFailed
+		}
+		return false, fmt.Sprintf("phase %s", pvc.Status.Phase), nil
+	}
+
+	return true, "", nil
+}
+
+func deploymentStatus(d *appsv1.Deployment) (bool, string, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for rollout to be observed", nil
+	}
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Sprintf("rollout exceeded its progress deadline: %s", cond.Message), errObjectFailed
+		}
+	}
+
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, replicas), nil
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), nil
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), nil
+	}
+	return true, "", nil
+}
+
+func statefulSetStatus(sts *appsv1.StatefulSet) (bool, string, error) {
+	if sts.Generation > sts.Status.ObservedGeneration {
+		return false, "waiting for rollout to be observed", nil
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", sts.Status.ReadyReplicas, replicas), nil
+	}
+	if sts.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType &&
+		sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d of %d replicas updated", sts.Status.UpdatedReplicas, replicas), nil
+	}
+	return true, "", nil
+}
+
+func daemonSetStatus(ds *appsv1.DaemonSet) (bool, string, error) {
+	if ds.Generation > ds.Status.ObservedGeneration {
+		return false, "waiting for rollout to be observed", nil
+	}
+
+	desired := ds.Status.DesiredNumberScheduled
+	if ds.Status.UpdatedNumberScheduled < desired {
+		return false, fmt.Sprintf("%d of %d pods updated", ds.Status.UpdatedNumberScheduled, desired), nil
+	}
+	if ds.Status.NumberAvailable < desired {
+		return false, fmt.Sprintf("%d of %d updated pods available", ds.Status.NumberAvailable, desired), nil
+	}
+	return true, "", nil
+}
+
+func jobStatus(job *batchv1.Job) (bool, string, error) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, "", nil
+		case batchv1.JobFailed:
+			return false, fmt.Sprintf("job failed: %s", cond.Message), errObjectFailed
+		}
+	}
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		return true, "", nil
+	}
+	return false, fmt.Sprintf("%d active, %d succeeded, %d failed pods", job.Status.Active, job.Status.Succeeded, job.Status.Failed), nil
+}
+
+// waitError builds a WaitError with the latest events of every unready object.
+func (c *Client) waitError(ctx context.Context, reason string, unready map[ObjectKey]string) error {
+	werr := &WaitError{Reason: reason}
+	for _, key := range sortedKeys(unready) {
+		werr.Unready = append(werr.Unready, UnreadyObject{
+			Key:    key,
+			Status: unready[key],
+			Events: c.latestEvents(ctx, key),
+		})
+	}
+	return werr
+}
+
+// latestEvents returns the most recent events recorded for the object.
+func (c *Client) latestEvents(ctx context.Context, key ObjectKey) []string {
+	selector := fields.Set{
+		"involvedObject.kind": key.Kind,
+		"involvedObject.name": key.Name,
+	}.AsSelector().String()
+
+	events, err := c.Clientset.CoreV1().Events(key.Namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return []string{fmt.Sprintf("failed to list events: %v", err)}
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).Before(eventTime(items[j]))
+	})
+	if len(items) > eventsPerObject {
+		items = items[len(items)-eventsPerObject:]
+	}
+
+	out := make([]string, 0, len(items))
+	for _, ev := range items {
+		out = append(out, fmt.Sprintf("%s %s: %s", ev.Type, ev.Reason, ev.Message))
+	}
+	return out
+}
+
+func eventTime(ev corev1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	}
+	return ev.CreationTimestamp.Time
+}
+
+func sortedKeys(m map[ObjectKey]string) []ObjectKey {
+	keys := make([]ObjectKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
@@ -3,52 +3,77 @@ package list
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	"github.com/morheus9/k8s-backup-cli/internal/output"
 )
 
-// ListResources lists all resources in the specified namespace
-func ListResources(namespace string, kubeconfigPath string) error {
-	var client *k8s.Client
-	var err error
-
-	if kubeconfigPath != "" {
-		client, err = k8s.NewClient(kubeconfigPath)
-	} else {
-		client, err = k8s.NewClientFromDefault()
-	}
-	if err != nil {
-		return fmt.Errorf("failed to create Kubernetes client: %w", err)
-	}
+// Result is the -o json|yaml document of the list command.
+type Result struct {
+	Namespace string             `json:"namespace"`
+	Resources []k8s.ResourceInfo `json:"resources"`
+}
 
-	ctx := context.Background()
+// ListResources lists all resources in the specified namespace
+func ListResources(ctx context.Context, client *k8s.Client, namespace string, format output.Format) error {
+	start := time.Now()
 	resources, err := client.FetchResources(ctx, namespace)
 	if err != nil {
 		return fmt.Errorf("failed to fetch resources: %w", err)
 	}
+	slog.InfoContext(ctx, "listed resources", "namespace", namespace, "count", len(resources), "duration", time.Since(start))
+
+	switch format {
+	case output.JSON, output.YAML:
+		if resources == nil {
+			resources = []k8s.ResourceInfo{}
+		}
+		return output.Write(os.Stdout, format, Result{Namespace: namespace, Resources: resources})
+	case output.Name:
+		return PrintNames(os.Stdout, resources)
+	}
 
 	if len(resources) == 0 {
 		fmt.Printf("No resources found in namespace '%s'\n", namespace)
 		return nil
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	if _, err := fmt.Fprintf(w, "KIND\tNAME\tNAMESPACE\tAPI VERSION\n"); err != nil {
+	return PrintResources(os.Stdout, resources, format == output.Wide)
+}
+
+// PrintResources renders resources as a KIND/NAME/NAMESPACE/API VERSION table
+// followed by a total count. With wide, a LABELS column is added.
+func PrintResources(out io.Writer, resources []k8s.ResourceInfo, wide bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	header, separator := "KIND\tNAME\tNAMESPACE\tAPI VERSION", "----\t----\t---------\t-----------"
+	if wide {
+		header, separator = header+"\tLABELS", separator+"\t------"
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
-	if _, err := fmt.Fprintf(w, "----\t----\t---------\t-----------\n"); err != nil {
+	if _, err := fmt.Fprintln(w, separator); err != nil {
 		return fmt.Errorf("failed to write separator: %w", err)
 	}
 
 	for _, resource := range resources {
-		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		line := fmt.Sprintf("%s\t%s\t%s\t%s",
 			resource.Kind,
 			resource.Name,
 			resource.Namespace,
 			resource.APIVersion,
-		); err != nil {
+		)
+		if wide {
+			line += "\t" + formatLabels(resource.Labels)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
 			return fmt.Errorf("failed to write resource: %w", err)
 		}
 	}
@@ -56,7 +81,31 @@ func ListResources(namespace string, kubeconfigPath string) error {
 	if err := w.Flush(); err != nil {
 		return fmt.Errorf("failed to flush output: %w", err)
 	}
-	fmt.Printf("\nTotal: %d resources\n", len(resources))
+	if _, err := fmt.Fprintf(out, "\nTotal: %d resources\n", len(resources)); err != nil {
+		return fmt.Errorf("failed to write total: %w", err)
+	}
 
 	return nil
 }
+
+// PrintNames prints one kind/name reference per resource.
+func PrintNames(out io.Writer, resources []k8s.ResourceInfo) error {
+	names := make([]string, 0, len(resources))
+	for _, r := range resources {
+		names = append(names, output.ObjectName(r.Kind, r.Name))
+	}
+	return output.WriteNames(out, names)
+}
+
+// formatLabels renders labels as sorted key=value pairs, or <none>.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,201 @@
+// Package metrics records Prometheus metrics about backup and restore runs
+// and Kubernetes API errors. They are served on /metrics by the long-running
+// modes and written to a node-exporter textfile by one-shot commands.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const namespace = "kubectl_backup"
+
+// Registry holds the kubectl_backup metrics of this package. It is what
+// WriteTextfile writes.
+var Registry = prometheus.NewRegistry()
+
+// runtimeRegistry holds the Go runtime and process metrics, which are only
+// served by Serve: in a textfile they would describe a process that has
+// already exited and clash with the node-exporter's own metrics.
+var runtimeRegistry = prometheus.NewRegistry()
+
+var (
+	backupRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backup_runs_total",
+		Help:      "Backup runs by namespace and result (success, partial, failure).",
+	}, []string{"namespace", "result"})
+	backupLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful or partial backup of the namespace.",
+	}, []string{"namespace"})
+	backupDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_duration_seconds",
+		Help:      "Duration of the last backup run of the namespace.",
+	}, []string{"namespace"})
+	backupObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_objects",
+		Help:      "Objects in the last backup of the namespace by kind.",
+	}, []string{"namespace", "kind"})
+	backupArchiveBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_archive_bytes",
+		Help:      "Size of the last backup archive of the namespace.",
+	}, []string{"namespace"})
+	backupFailedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_failed_resource_types",
+		Help:      "Resource types missing from the last backup of the namespace.",
+	}, []string{"namespace"})
+
+	restoreRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "restore_runs_total",
+		Help:      "Restore runs by result (success, failure) and whether they were dry runs.",
+	}, []string{"result", "dry_run"})
+	restoreDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "restore_duration_seconds",
+		Help:      "Duration of the last restore run.",
+	})
+	restoreObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "restore_objects_total",
+		Help:      "Objects handled by restores by namespace, kind and action (created, updated, recreated, skipped).",
+	}, []string{"namespace", "kind", "action"})
+
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_errors_total",
+		Help:      "Kubernetes API requests that failed with a throttling, server or transport error by HTTP status code and method.",
+	}, []string{"code", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		backupRuns, backupLastSuccess, backupDuration, backupObjects, backupArchiveBytes, backupFailedResources,
+		restoreRuns, restoreDuration, restoreObjects,
+		apiErrors,
+	)
+	runtimeRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RequestResult counts requests made by client-go that failed with 429, a
+// 5xx status or a transport error. Expected client errors such as 404 on
+// existence checks or 409 on create are not counted. It is registered with
+// client-go by the k8s package.
+var RequestResult clientmetrics.ResultMetric = apiResult{}
+
+type apiResult struct{}
+
+func (apiResult) Increment(_ context.Context, code, method, _ string) {
+	// code is an HTTP status or "<error>" for transport failures.
+	if status, err := strconv.Atoi(code); err == nil && status != http.StatusTooManyRequests && status < 500 {
+		return
+	}
+	apiErrors.WithLabelValues(code, method).Inc()
+}
+
+// Backup describes a finished backup run.
+type Backup struct {
+	Namespace string
+	Duration  time.Duration
+	// Objects counts the archived objects by kind.
+	Objects map[string]int
+	// ArchiveBytes is the size of the written archive.
+	ArchiveBytes int64
+	// FailedResourceTypes is the number of resource types a best-effort
+	// backup could not read.
+	FailedResourceTypes int
+	Err                 error
+}
+
+// ObserveBackup records a backup run.
+func ObserveBackup(b Backup) {
+	backupDuration.WithLabelValues(b.Namespace).Set(b.Duration.Seconds())
+	if b.Err != nil {
+		backupRuns.WithLabelValues(b.Namespace, "failure").Inc()
+		return
+	}
+
+	result := "success"
+	if b.FailedResourceTypes > 0 {
+		result = "partial"
+	}
+	backupRuns.WithLabelValues(b.Namespace, result).Inc()
+	backupLastSuccess.WithLabelValues(b.Namespace).SetToCurrentTime()
+	backupArchiveBytes.WithLabelValues(b.Namespace).Set(float64(b.ArchiveBytes))
+	backupFailedResources.WithLabelValues(b.Namespace).Set(float64(b.FailedResourceTypes))
+	backupObjects.DeletePartialMatch(prometheus.Labels{"namespace": b.Namespace})
+	for kind, n := range b.Objects {
+		backupObjects.WithLabelValues(b.Namespace, kind).Set(float64(n))
+	}
+}
+
+// RestoredObject is the outcome of one restored object.
+type RestoredObject struct {
+	Namespace string
+	Kind      string
+	Action    string
+}
+
+// ObserveRestore records a restore run.
+func ObserveRestore(duration time.Duration, objects []RestoredObject, dryRun bool, err error) {
+	restoreDuration.Set(duration.Seconds())
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	restoreRuns.WithLabelValues(result, strconv.FormatBool(dryRun)).Inc()
+	if dryRun {
+		return
+	}
+	for _, obj := range objects {
+		restoreObjects.WithLabelValues(obj.Namespace, obj.Kind, obj.Action).Inc()
+	}
+}
+
+// WriteTextfile writes all metrics to path in the Prometheus text format,
+// replacing the file atomically as the node-exporter textfile collector
+// expects.
+func WriteTextfile(path string) error {
+	if err := prometheus.WriteToTextfile(path, Registry); err != nil {
+		return fmt.Errorf("write metrics textfile: %w", err)
+	}
+	return nil
+}
+
+// Serve serves the metrics on addr under /metrics until ctx is cancelled.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	gatherers := prometheus.Gatherers{Registry, runtimeRegistry}
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve metrics: %w", err)
+	}
+	return nil
+}
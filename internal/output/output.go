@@ -0,0 +1,102 @@
+// Package output renders command results in the format selected with -o.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Format is an output format of the -o flag.
+type Format string
+
+const (
+	// Table is the human-readable default.
+	Table Format = "table"
+	// Wide is Table with additional columns where a command has them.
+	Wide Format = "wide"
+	// JSON writes the result as one indented JSON document.
+	JSON Format = "json"
+	// YAML writes the result as one YAML document.
+	YAML Format = "yaml"
+	// Name writes one kind/name reference (or archive path) per line.
+	Name Format = "name"
+)
+
+// Formats lists the accepted formats in the order they are documented.
+var Formats = []Format{Table, Wide, JSON, YAML, Name}
+
+// FlagUsage is the usage string of the -o flag.
+var FlagUsage = "Output format: " + join(Formats)
+
+// ParseFormat parses the value of the -o flag.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if Format(s) == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q: must be one of %s", s, join(Formats))
+}
+
+// Tabular reports whether f is one of the human-readable formats, which may
+// include progress and summary lines.
+func (f Format) Tabular() bool {
+	return f == Table || f == Wide
+}
+
+// Structured reports whether f is a machine-readable document format.
+func (f Format) Structured() bool {
+	return f == JSON || f == YAML
+}
+
+// Write encodes v to w as JSON or YAML. The field names are those of the
+// json tags of v, which form the stable schema of a command's result.
+func Write(w io.Writer, f Format, v any) error {
+	var data []byte
+	var err error
+	switch f {
+	case JSON:
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	case YAML:
+		data, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("output format %q is not a document format", f)
+	}
+	if err != nil {
+		return fmt.Errorf("encode %s output: %w", f, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// WriteNames writes one name per line.
+func WriteNames(w io.Writer, names []string) error {
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return nil
+}
+
+// ObjectName returns the kind/name reference of an object as printed by
+// -o name, e.g. deployment/web. It is accepted wherever the commands take
+// kind/name arguments.
+func ObjectName(kind, name string) string {
+	return strings.ToLower(kind) + "/" + name
+}
+
+func join(formats []Format) string {
+	s := make([]string, len(formats))
+	for i, f := range formats {
+		s[i] = string(f)
+	}
+	return strings.Join(s, "|")
+}
@@ -0,0 +1,423 @@
+// Package preflight checks whether the objects of a backup can be restored
+// into a cluster before anything is written: that the classes, CRDs and
+// ClusterRoles they depend on exist and that the namespace quotas can fit
+// the restored workloads.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is one line of the preflight checklist.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Result is the checklist produced by Run.
+type Result struct {
+	Checks []Check
+}
+
+// Failed reports whether any check failed.
+func (r *Result) Failed() bool {
+	return r.Count(StatusFail) > 0
+}
+
+// Count returns the number of checks with the given status.
+func (r *Result) Count(status Status) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Result) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// dependency is a cluster-scoped object that restored objects refer to.
+type dependency struct {
+	kind string
+	name string
+}
+
+// Run checks objs against the cluster of client. Namespaced objects without
+// a namespace are assumed to go to namespaceFallback. objs are not modified.
+func Run(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, namespaceFallback string) (*Result, error) {
+	result := &Result{}
+
+	// Check the objects as they will be applied, i.e. after API upgrades.
+	converted := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		obj = obj.DeepCopy()
+		if _, _, err := k8s.ConvertDeprecated(obj); err != nil {
+			return nil, err
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespaceFallback)
+		}
+		converted = append(converted, obj)
+	}
+
+	if err := checkNamespaces(ctx, client, converted, result); err != nil {
+		return nil, err
+	}
+	checkAPIs(client, converted, result)
+	if err := checkDependencies(ctx, client, converted, result); err != nil {
+		return nil, err
+	}
+	if err := checkQuotas(ctx, client, converted, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// checkNamespaces verifies that the target namespaces exist, unless the
+// archive creates them.
+func checkNamespaces(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, result *Result) error {
+	created := make(map[string]bool)
+	for _, obj := range objs {
+		if obj.GetKind() == "Namespace" {
+			created[obj.GetName()] = true
+		}
+	}
+
+	for _, ns := range namespacesOf(objs) {
+		if created[ns] {
+			result.add("Namespace", StatusPass, "%s (created by the restore)", ns)
+			continue
+		}
+		_, err := client.Clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			result.add("Namespace", StatusPass, "%s", ns)
+		case apierrors.IsNotFound(err):
+			result.add("Namespace", StatusFail, "%s not found", ns)
+		default:
+			return fmt.Errorf("get namespace %s: %w", ns, err)
+		}
+	}
+	return nil
+}
+
+// checkAPIs verifies that the cluster serves every kind in the archive, which
+// fails for custom resources whose CRD is not installed.
+func checkAPIs(client *k8s.Client, objs []*unstructured.Unstructured, result *Result) {
+	seen := make(map[string]bool)
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		key := gvk.GroupVersion().String() + " " + gvk.Kind
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if _, err := client.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			result.add("API", StatusFail, "%s %s not served by the cluster (missing CRD?)", gvk.GroupVersion().String(), gvk.Kind)
+			continue
+		}
+		result.add("API", StatusPass, "%s %s", gvk.GroupVersion().String(), gvk.Kind)
+	}
+}
+
+// checkDependencies verifies that the StorageClasses, IngressClasses,
+// PriorityClasses, RuntimeClasses and ClusterRoles referenced by objs exist.
+func checkDependencies(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, result *Result) error {
+	refs := make(map[dependency][]string)
+	var defaultStorageClassUsers []string
+	for _, obj := range objs {
+		user := obj.GetKind() + "/" + obj.GetName()
+		deps, usesDefaultStorageClass := dependenciesOf(obj)
+		for _, dep := range deps {
+			refs[dep] = append(refs[dep], user)
+		}
+		if usesDefaultStorageClass {
+			defaultStorageClassUsers = append(defaultStorageClassUsers, user)
+		}
+	}
+
+	deps := make([]dependency, 0, len(refs))
+	for dep := range refs {
+		deps = append(deps, dep)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].kind != deps[j].kind {
+			return deps[i].kind < deps[j].kind
+		}
+		return deps[i].name < deps[j].name
+	})
+
+	for _, dep := range deps {
+		err := getDependency(ctx, client, dep)
+		users := strings.Join(refs[dep], ", ")
+		switch {
+		case err == nil:
+			result.add(dep.kind, StatusPass, "%s (used by %s)", dep.name, users)
+		case apierrors.IsNotFound(err):
+			result.add(dep.kind, StatusFail, "%s not found (used by %s)", dep.name, users)
+		default:
+			return fmt.Errorf("get %s %s: %w", dep.kind, dep.name, err)
+		}
+	}
+
+	if len(defaultStorageClassUsers) > 0 {
+		name, err := defaultStorageClass(ctx, client)
+		if err != nil {
+			return err
+		}
+		users := strings.Join(defaultStorageClassUsers, ", ")
+		if name == "" {
+			result.add("StorageClass", StatusWarn, "no default StorageClass; claims without storageClassName stay pending (used by %s)", users)
+		} else {
+			result.add("StorageClass", StatusPass, "%s (default, used by %s)", name, users)
+		}
+	}
+
+	return nil
+}
+
+// dependencyResources maps dependency kinds to their group and resource.
+var dependencyResources = map[string]struct{ group, resource string }{
+	"StorageClass":  {"storage.k8s.io", "storageclasses"},
+	"IngressClass":  {"networking.k8s.io", "ingressclasses"},
+	"PriorityClass": {"scheduling.k8s.io", "priorityclasses"},
+	"RuntimeClass":  {"node.k8s.io", "runtimeclasses"},
+	"ClusterRole":   {"rbac.authorization.k8s.io", "clusterroles"},
+}
+
+// preflightPermissions returns the permissions Run needs to check objs:
+// reading the target namespaces, the cluster-scoped objects they depend on
+// and the ResourceQuotas of their namespaces.
+func preflightPermissions(objs []*unstructured.Unstructured, namespaceFallback string) []Permission {
+	perms := []Permission{{Resource: "namespaces", Verb: "get"}}
+	seen := make(map[Permission]bool)
+	add := func(p Permission) {
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+
+	namespaces := make(map[string]bool)
+	for _, obj := range objs {
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = namespaceFallback
+		}
+		if ns != "" && obj.GetKind() != "Namespace" {
+			namespaces[ns] = true
+		}
+
+		deps, usesDefaultStorageClass := dependenciesOf(obj)
+		for _, dep := range deps {
+			r := dependencyResources[dep.kind]
+			add(Permission{Group: r.group, Resource: r.resource, Verb: "get"})
+		}
+		if usesDefaultStorageClass {
+			add(Permission{Group: "storage.k8s.io", Resource: "storageclasses", Verb: "list"})
+		}
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	for _, ns := range names {
+		add(Permission{Namespace: ns, Resource: "resourcequotas", Verb: "list"})
+	}
+	return perms
+}
+
+func getDependency(ctx context.Context, client *k8s.Client, dep dependency) error {
+	var err error
+	switch dep.kind {
+	case "StorageClass":
+		_, err = client.Clientset.StorageV1().StorageClasses().Get(ctx, dep.name, metav1.GetOptions{})
+	case "IngressClass":
+		_, err = client.Clientset.NetworkingV1().IngressClasses().Get(ctx, dep.name, metav1.GetOptions{})
+	case "PriorityClass":
+		_, err = client.Clientset.SchedulingV1().PriorityClasses().Get(ctx, dep.name, metav1.GetOptions{})
+	case "RuntimeClass":
+		_, err = client.Clientset.NodeV1().RuntimeClasses().Get(ctx, dep.name, metav1.GetOptions{})
+	case "ClusterRole":
+		_, err = client.Clientset.RbacV1().ClusterRoles().Get(ctx, dep.name, metav1.GetOptions{})
+	default:
+		err = fmt.Errorf("unsupported dependency kind %s", dep.kind)
+	}
+	return err
+}
+
+// defaultStorageClass returns the name of the cluster's default
+// StorageClass, or "" if there is none.
+func defaultStorageClass(ctx context.Context, client *k8s.Client) (string, error) {
+	classes, err := client.Clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("list StorageClasses: %w", err)
+	}
+	for _, sc := range classes.Items {
+		if sc.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			return sc.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// dependenciesOf returns the cluster-scoped objects obj refers to and whether
+// it creates volumes with the default StorageClass.
+func dependenciesOf(obj *unstructured.Unstructured) ([]dependency, bool) {
+	var (
+		deps        []dependency
+		usesDefault bool
+	)
+
+	claim := func(spec map[string]interface{}) {
+		name, ok, _ := unstructured.NestedString(spec, "storageClassName")
+		_, bound, _ := unstructured.NestedString(spec, "volumeName")
+		switch {
+		case ok && name != "":
+			deps = append(deps, dependency{kind: "StorageClass", name: name})
+		case !ok && !bound:
+			// Claims bound to a pre-provisioned volume do not use a class.
+			usesDefault = true
+		}
+	}
+
+	switch obj.GetKind() {
+	case "PersistentVolumeClaim":
+		if spec, ok, _ := unstructured.NestedMap(obj.Object, "spec"); ok {
+			claim(spec)
+		}
+	case "StatefulSet":
+		templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+		for _, t := range templates {
+			if template, ok := t.(map[string]interface{}); ok {
+				if spec, ok, _ := unstructured.NestedMap(template, "spec"); ok {
+					claim(spec)
+				}
+			}
+		}
+	case "Ingress":
+		if name, ok, _ := unstructured.NestedString(obj.Object, "spec", "ingressClassName"); ok && name != "" {
+			deps = append(deps, dependency{kind: "IngressClass", name: name})
+		}
+	case "RoleBinding":
+		kind, _, _ := unstructured.NestedString(obj.Object, "roleRef", "kind")
+		name, _, _ := unstructured.NestedString(obj.Object, "roleRef", "name")
+		if kind == "ClusterRole" && name != "" {
+			deps = append(deps, dependency{kind: "ClusterRole", name: name})
+		}
+	}
+
+	if pod, _, ok := podTemplate(obj); ok {
+		if name, ok, _ := unstructured.NestedString(pod, "priorityClassName"); ok && name != "" {
+			deps = append(deps, dependency{kind: "PriorityClass", name: name})
+		}
+		if name, ok, _ := unstructured.NestedString(pod, "runtimeClassName"); ok && name != "" {
+			deps = append(deps, dependency{kind: "RuntimeClass", name: name})
+		}
+	}
+
+	return deps, usesDefault
+}
+
+// podTemplate returns the pod spec of a workload and the number of pods it
+// runs at once.
+func podTemplate(obj *unstructured.Unstructured) (map[string]interface{}, int64, bool) {
+	var (
+		path     []string
+		replicas int64 = 1
+	)
+	switch obj.GetKind() {
+	case "Pod":
+		path = []string{"spec"}
+	case "Deployment", "StatefulSet", "ReplicaSet", "ReplicationController":
+		path = []string{"spec", "template", "spec"}
+		if n, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+			replicas = n
+		}
+	case "DaemonSet":
+		// One pod per node; counted once as the node count is not known here.
+		path = []string{"spec", "template", "spec"}
+	case "Job":
+		path = []string{"spec", "template", "spec"}
+		if n, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "parallelism"); ok {
+			replicas = n
+		}
+	case "CronJob":
+		// Pods are only created on schedule.
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+		replicas = 0
+	default:
+		return nil, 0, false
+	}
+
+	spec, ok, _ := unstructured.NestedMap(obj.Object, path...)
+	return spec, replicas, ok
+}
+
+func namespacesOf(objs []*unstructured.Unstructured) []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, obj := range objs {
+		ns := obj.GetNamespace()
+		if ns == "" || obj.GetKind() == "Namespace" || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Print writes the checklist and a summary line to out.
+func Print(out io.Writer, result *Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "STATUS\tCHECK\tDETAIL\n"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, c := range result.Checks {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail); err != nil {
+			return fmt.Errorf("failed to write check: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+
+	status := "passed"
+	if result.Failed() {
+		status = "failed"
+	}
+	if _, err := fmt.Fprintf(out, "\nPreflight %s: %d passed, %d warnings, %d failed\n",
+		status, result.Count(StatusPass), result.Count(StatusWarn), result.Count(StatusFail)); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
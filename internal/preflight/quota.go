@@ -0,0 +1,304 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectCountResources maps kinds to the ResourceQuota resources that count
+// their objects.
+var objectCountResources = map[string][]corev1.ResourceName{
+	"ConfigMap":             {corev1.ResourceConfigMaps, "count/configmaps"},
+	"Secret":                {corev1.ResourceSecrets, "count/secrets"},
+	"Service":               {corev1.ResourceServices, "count/services"},
+	"PersistentVolumeClaim": {corev1.ResourcePersistentVolumeClaims, "count/persistentvolumeclaims"},
+	"Deployment":            {"count/deployments.apps"},
+	"StatefulSet":           {"count/statefulsets.apps"},
+	"DaemonSet":             {"count/daemonsets.apps"},
+	"Job":                   {"count/jobs.batch"},
+	"CronJob":               {"count/cronjobs.batch"},
+	"Ingress":               {"count/ingresses.networking.k8s.io"},
+}
+
+// checkQuotas verifies that the ResourceQuotas of every target namespace
+// have room for the restored objects. Objects that already exist only count
+// with the difference to their live state, e.g. added replicas.
+func checkQuotas(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured, result *Result) error {
+	byNamespace := make(map[string][]*unstructured.Unstructured)
+	for _, obj := range objs {
+		if ns := obj.GetNamespace(); ns != "" && obj.GetKind() != "Namespace" {
+			byNamespace[ns] = append(byNamespace[ns], obj)
+		}
+	}
+
+	for _, ns := range namespacesOf(objs) {
+		quotas, err := client.Clientset.CoreV1().ResourceQuotas(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("list ResourceQuotas in %s: %w", ns, err)
+		}
+		if len(quotas.Items) == 0 {
+			continue
+		}
+
+		live, err := liveObjects(ctx, client, byNamespace[ns])
+		if err != nil {
+			return err
+		}
+		needed, err := quotaDelta(byNamespace[ns], live)
+		if err != nil {
+			return err
+		}
+
+		for _, quota := range quotas.Items {
+			if short := quotaShortfalls(quota, needed); len(short) > 0 {
+				result.add("ResourceQuota", StatusFail, "%s/%s: %s", ns, quota.Name, strings.Join(short, "; "))
+			} else {
+				result.add("ResourceQuota", StatusPass, "%s/%s has room for the restored objects", ns, quota.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// liveObjects returns the live counterparts of objs that already exist in
+// the cluster. Kinds the cluster does not serve are skipped.
+func liveObjects(ctx context.Context, client *k8s.Client, objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	type kindScope struct {
+		gvk       schema.GroupVersionKind
+		namespace string
+	}
+	wanted := make(map[kindScope]map[string]bool)
+	var scopes []kindScope
+	for _, obj := range objs {
+		scope := kindScope{gvk: obj.GroupVersionKind(), namespace: obj.GetNamespace()}
+		if wanted[scope] == nil {
+			wanted[scope] = make(map[string]bool)
+			scopes = append(scopes, scope)
+		}
+		wanted[scope][obj.GetName()] = true
+	}
+
+	var live []*unstructured.Unstructured
+	for _, scope := range scopes {
+		items, err := client.ListObjects(ctx, scope.gvk, scope.namespace)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			if wanted[scope][items[i].GetName()] {
+				// List returns items without kind.
+				items[i].SetGroupVersionKind(scope.gvk)
+				live = append(live, &items[i])
+			}
+		}
+	}
+	return live, nil
+}
+
+// quotaDelta returns how much restoring objs changes the usage of
+// ResourceQuota resources, given the live objects they replace. Resources
+// whose usage does not grow are left out.
+func quotaDelta(objs, live []*unstructured.Unstructured) (corev1.ResourceList, error) {
+	needed, err := requestedResources(objs)
+	if err != nil {
+		return nil, err
+	}
+	used, err := requestedResources(live)
+	if err != nil {
+		return nil, err
+	}
+	for name, q := range used {
+		want := needed[name]
+		want.Sub(q)
+		if want.Sign() > 0 {
+			needed[name] = want
+		} else {
+			delete(needed, name)
+		}
+	}
+	return needed, nil
+}
+
+// quotaShortfalls returns a description of every resource of quota that has
+// less room left than needed, sorted by resource name.
+func quotaShortfalls(quota corev1.ResourceQuota, needed corev1.ResourceList) []string {
+	names := make([]string, 0, len(quota.Status.Hard))
+	for name := range quota.Status.Hard {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	var short []string
+	for _, name := range names {
+		want, ok := needed[corev1.ResourceName(name)]
+		if !ok || want.Sign() <= 0 {
+			continue
+		}
+		available := quota.Status.Hard[corev1.ResourceName(name)].DeepCopy()
+		available.Sub(quota.Status.Used[corev1.ResourceName(name)])
+		if want.Cmp(available) > 0 {
+			short = append(short, fmt.Sprintf("%s needs %s, %s available", name, want.String(), available.String()))
+		}
+	}
+	return short
+}
+
+// requestedResources sums what objs consume of ResourceQuota resources:
+// object counts, pods, compute requests and limits and requested storage,
+// including the claims StatefulSets create from their volumeClaimTemplates
+// unless objs contain them already.
+func requestedResources(objs []*unstructured.Unstructured) (corev1.ResourceList, error) {
+	total := corev1.ResourceList{}
+	add := func(name corev1.ResourceName, q resource.Quantity) {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+
+	claims := make(map[string]bool)
+	for _, obj := range objs {
+		if obj.GetKind() == "PersistentVolumeClaim" {
+			claims[obj.GetNamespace()+"/"+obj.GetName()] = true
+		}
+	}
+
+	for _, obj := range objs {
+		for _, name := range objectCountResources[obj.GetKind()] {
+			add(name, *resource.NewQuantity(1, resource.DecimalSI))
+		}
+
+		if obj.GetKind() == "PersistentVolumeClaim" {
+			q, ok, err := claimStorage(obj.Object)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			if ok {
+				add(corev1.ResourceRequestsStorage, q)
+			}
+		}
+
+		if obj.GetKind() == "StatefulSet" {
+			// Every replica gets its own claim from each volumeClaimTemplate.
+			replicas := int64(1)
+			if n, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+				replicas = n
+			}
+			templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+			for _, t := range templates {
+				claim, ok := t.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				// Claims are named <template>-<statefulset>-<ordinal>.
+				template, _, _ := unstructured.NestedString(claim, "metadata", "name")
+				var missing int64
+				for i := int64(0); i < replicas; i++ {
+					if !claims[fmt.Sprintf("%s/%s-%s-%d", obj.GetNamespace(), template, obj.GetName(), i)] {
+						missing++
+					}
+				}
+				if missing == 0 {
+					continue
+				}
+				for _, name := range objectCountResources["PersistentVolumeClaim"] {
+					add(name, *resource.NewQuantity(missing, resource.DecimalSI))
+				}
+				q, ok, err := claimStorage(claim)
+				if err != nil {
+					return nil, fmt.Errorf("%s/%s: volumeClaimTemplate: %w", obj.GetKind(), obj.GetName(), err)
+				}
+				if ok {
+					q.Mul(missing)
+					add(corev1.ResourceRequestsStorage, q)
+				}
+			}
+		}
+
+		pod, replicas, ok := podTemplate(obj)
+		if !ok || replicas == 0 {
+			continue
+		}
+		var spec corev1.PodSpec
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(pod, &spec); err != nil {
+			return nil, fmt.Errorf("%s/%s: decode pod template: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		add(corev1.ResourcePods, *resource.NewQuantity(replicas, resource.DecimalSI))
+		for name, q := range podResources(spec) {
+			q.Mul(replicas)
+			add(name, q)
+		}
+	}
+
+	return total, nil
+}
+
+// claimStorage returns the storage request of a PersistentVolumeClaim or
+// volumeClaimTemplate.
+func claimStorage(claim map[string]interface{}) (resource.Quantity, bool, error) {
+	storage, ok, _ := unstructured.NestedString(claim, "spec", "resources", "requests", "storage")
+	if !ok {
+		return resource.Quantity{}, false, nil
+	}
+	q, err := resource.ParseQuantity(storage)
+	if err != nil {
+		return resource.Quantity{}, false, fmt.Errorf("invalid storage request %q: %w", storage, err)
+	}
+	return q, true, nil
+}
+
+// podResources returns the quota-relevant requests and limits of one pod:
+// the sum over containers, or the largest init container if that is higher.
+func podResources(spec corev1.PodSpec) corev1.ResourceList {
+	sum := func(containers []corev1.Container) corev1.ResourceList {
+		total := corev1.ResourceList{}
+		for _, c := range containers {
+			addContainer(total, c)
+		}
+		return total
+	}
+
+	total := sum(spec.Containers)
+	for _, c := range spec.InitContainers {
+		single := corev1.ResourceList{}
+		addContainer(single, c)
+		for name, q := range single {
+			if current, ok := total[name]; !ok || q.Cmp(current) > 0 {
+				total[name] = q
+			}
+		}
+	}
+	return total
+}
+
+func addContainer(total corev1.ResourceList, c corev1.Container) {
+	add := func(name corev1.ResourceName, q resource.Quantity) {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+	for name, q := range c.Resources.Requests {
+		add(corev1.ResourceName("requests."+string(name)), q)
+		if name == corev1.ResourceCPU || name == corev1.ResourceMemory {
+			// Plain cpu and memory quotas limit requests.
+			add(name, q)
+		}
+	}
+	for name, q := range c.Resources.Limits {
+		add(corev1.ResourceName("limits."+string(name)), q)
+	}
+}
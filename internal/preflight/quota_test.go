@@ -0,0 +1,307 @@
+package preflight
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s/k8stest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func decode(t *testing.T, manifests ...string) []*unstructured.Unstructured {
+	t.Helper()
+	objs := make([]*unstructured.Unstructured, 0, len(manifests))
+	for _, m := range manifests {
+		data, err := yaml.YAMLToJSON([]byte(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(data); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+// resources builds a ResourceList from name/quantity pairs.
+func resources(pairs ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i < len(pairs); i += 2 {
+		list[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return list
+}
+
+func equalResources(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, q := range a {
+		other, ok := b[name]
+		if !ok || q.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	webDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web, namespace: shop}
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        resources: {requests: {cpu: "2"}}
+      containers:
+      - name: web
+        resources: {requests: {cpu: 500m, memory: 256Mi}, limits: {memory: 512Mi}}
+      - name: proxy
+        resources: {requests: {cpu: 100m}}
+`
+	webDeploymentOneReplica = `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web, namespace: shop}
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        resources: {requests: {cpu: 500m, memory: 256Mi}, limits: {memory: 512Mi}}
+      - name: proxy
+        resources: {requests: {cpu: 100m}}
+`
+	dbStatefulSet = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata: {name: db, namespace: shop}
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: db
+  volumeClaimTemplates:
+  - metadata: {name: data}
+    spec: {resources: {requests: {storage: 10Gi}}}
+`
+	dbClaim0 = `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: data-db-0, namespace: shop}
+spec: {resources: {requests: {storage: 10Gi}}}
+`
+	reportCronJob = `
+apiVersion: batch/v1
+kind: CronJob
+metadata: {name: report, namespace: shop}
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: report
+            resources: {requests: {cpu: "1"}}
+`
+)
+
+func TestRequestedResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests []string
+		want      corev1.ResourceList
+	}{
+		{
+			name:      "deployment with init container below the containers",
+			manifests: []string{webDeploymentOneReplica},
+			want: resources(
+				"count/deployments.apps", "1",
+				"pods", "1",
+				"requests.cpu", "600m", "cpu", "600m",
+				"requests.memory", "256Mi", "memory", "256Mi",
+				"limits.memory", "512Mi",
+			),
+		},
+		{
+			name:      "init container above the containers, times replicas",
+			manifests: []string{webDeployment},
+			want: resources(
+				"count/deployments.apps", "1",
+				"pods", "3",
+				"requests.cpu", "6", "cpu", "6",
+				"requests.memory", "768Mi", "memory", "768Mi",
+				"limits.memory", "1536Mi",
+			),
+		},
+		{
+			name:      "statefulset claims per replica",
+			manifests: []string{dbStatefulSet},
+			want: resources(
+				"count/statefulsets.apps", "1",
+				"pods", "2",
+				"persistentvolumeclaims", "2", "count/persistentvolumeclaims", "2",
+				"requests.storage", "20Gi",
+			),
+		},
+		{
+			name:      "statefulset claim in the archive is counted once",
+			manifests: []string{dbStatefulSet, dbClaim0},
+			want: resources(
+				"count/statefulsets.apps", "1",
+				"pods", "2",
+				"persistentvolumeclaims", "2", "count/persistentvolumeclaims", "2",
+				"requests.storage", "20Gi",
+			),
+		},
+		{
+			name:      "cronjob only counts itself",
+			manifests: []string{reportCronJob},
+			want:      resources("count/cronjobs.batch", "1"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requestedResources(decode(t, tt.manifests...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalResources(got, tt.want) {
+				t.Errorf("requestedResources = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestedResourcesRejectsInvalidStorage(t *testing.T) {
+	_, err := requestedResources(decode(t, `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: data, namespace: shop}
+spec: {resources: {requests: {storage: lots}}}
+`))
+	if err == nil {
+		t.Error("expected an error for an invalid storage request")
+	}
+}
+
+func TestQuotaDelta(t *testing.T) {
+	tests := []struct {
+		name string
+		objs []string
+		live []string
+		want corev1.ResourceList
+	}{
+		{
+			name: "new objects count in full",
+			objs: []string{webDeploymentOneReplica, dbClaim0},
+			want: resources(
+				"count/deployments.apps", "1",
+				"pods", "1",
+				"requests.cpu", "600m", "cpu", "600m",
+				"requests.memory", "256Mi", "memory", "256Mi",
+				"limits.memory", "512Mi",
+				"persistentvolumeclaims", "1", "count/persistentvolumeclaims", "1",
+				"requests.storage", "10Gi",
+			),
+		},
+		{
+			name: "unchanged objects need nothing",
+			objs: []string{webDeploymentOneReplica, dbClaim0},
+			live: []string{webDeploymentOneReplica, dbClaim0},
+			want: resources(),
+		},
+		{
+			name: "scaled up deployment counts the added replicas",
+			objs: []string{webDeployment},
+			live: []string{webDeploymentOneReplica},
+			want: resources(
+				"pods", "2",
+				"requests.cpu", "5400m", "cpu", "5400m",
+				"requests.memory", "512Mi", "memory", "512Mi",
+				"limits.memory", "1Gi",
+			),
+		},
+		{
+			name: "scaled down deployment needs nothing",
+			objs: []string{webDeploymentOneReplica},
+			live: []string{webDeployment},
+			want: resources(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quotaDelta(decode(t, tt.objs...), decode(t, tt.live...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalResources(got, tt.want) {
+				t.Errorf("quotaDelta = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaShortfalls(t *testing.T) {
+	quota := corev1.ResourceQuota{Status: corev1.ResourceQuotaStatus{
+		Hard: resources("requests.cpu", "4", "pods", "10", "requests.storage", "100Gi"),
+		Used: resources("requests.cpu", "3500m", "pods", "4"),
+	}}
+
+	tests := []struct {
+		name   string
+		needed corev1.ResourceList
+		want   []string
+	}{
+		{name: "fits", needed: resources("requests.cpu", "500m", "pods", "6", "requests.storage", "100Gi")},
+		{name: "nothing needed", needed: resources()},
+		{name: "not limited by the quota", needed: resources("limits.memory", "64Gi")},
+		{
+			name:   "exceeds",
+			needed: resources("requests.cpu", "600m", "pods", "7"),
+			want:   []string{"pods needs 7, 6 available", "requests.cpu needs 600m, 500m available"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quotaShortfalls(quota, tt.needed)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("quotaShortfalls = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiveObjects(t *testing.T) {
+	live := decode(t, webDeploymentOneReplica, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: shop}
+`)
+	client, _ := k8stest.NewClient(live[0], live[1])
+
+	objs := decode(t, webDeployment, dbStatefulSet, `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata: {name: web, namespace: shop}
+`)
+	got, err := liveObjects(context.Background(), client, objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].GetKind() != "Deployment" || got[0].GetName() != "web" {
+		t.Errorf("liveObjects = %v, want the live Deployment web", got)
+	}
+}
@@ -0,0 +1,320 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Permission is a verb on a resource, in a namespace or cluster-wide if
+// Namespace is empty. If Path is set, it is a verb on that non-resource URL
+// (e.g. /openapi/v3) instead, and Namespace, Group and Resource are empty.
+type Permission struct {
+	Namespace string
+	Group     string
+	Resource  string
+	Path      string
+	Verb      string
+}
+
+func (p Permission) String() string {
+	resource := p.resourceName()
+	if p.Namespace == "" {
+		return p.Verb + " " + resource
+	}
+	return p.Verb + " " + resource + " in " + p.Namespace
+}
+
+// resourceName returns resource.group, or the path of a non-resource URL.
+func (p Permission) resourceName() string {
+	if p.Path != "" {
+		return p.Path
+	}
+	if p.Group != "" {
+		return p.Resource + "." + p.Group
+	}
+	return p.Resource
+}
+
+// PermissionError is returned when the current user lacks permissions an
+// operation needs.
+type PermissionError struct {
+	Missing []Permission
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("missing %d permissions", len(e.Missing))
+}
+
+// backupResources are the resources read by the exporter.
+var backupResources = []struct{ group, resource string }{
+	{"", "configmaps"},
+	{"", "secrets"},
+	{"", "services"},
+	{"apps", "deployments"},
+	{"apps", "statefulsets"},
+	{"apps", "daemonsets"},
+	{"batch", "jobs"},
+	{"batch", "cronjobs"},
+	{"", "persistentvolumeclaims"},
+	{"networking.k8s.io", "ingresses"},
+}
+
+// BackupPermissions returns the permissions needed to back up namespace.
+func BackupPermissions(namespace string) []Permission {
+	perms := make([]Permission, 0, len(backupResources))
+	for _, r := range backupResources {
+		perms = append(perms, Permission{Namespace: namespace, Group: r.group, Resource: r.resource, Verb: "list"})
+	}
+	return perms
+}
+
+// RestoreNeeds describes what a restore does besides creating, reading and
+// updating the archived objects. It is derived from the restore options.
+type RestoreNeeds struct {
+	// Delete is set if objects may be deleted: for recreate, rollback and
+	// prune.
+	Delete bool
+	// List is set if the restored kinds are listed, for prune. The
+	// preflight lists them as well.
+	List bool
+	// Wait is set if the restore waits for workloads to become ready, which
+	// reads them and lists their events.
+	Wait bool
+	// Preflight is set if the preflight checks run before the restore.
+	Preflight bool
+	// SchemaCheck is set if objects are checked against the OpenAPI schema
+	// of the cluster.
+	SchemaCheck bool
+}
+
+// openAPIPaths are the non-resource URLs read by the schema check.
+var openAPIPaths = []string{"/openapi/v3", "/openapi/v3/*"}
+
+// RestorePermissions returns the permissions needed to restore objs. Objects
+// are created, read and updated; needs adds the permissions of the optional
+// steps of the restore. Kinds the cluster does not serve are skipped.
+func RestorePermissions(client *k8s.Client, objs []*unstructured.Unstructured, namespaceFallback string, needs RestoreNeeds) []Permission {
+	verbs := []string{"get", "create", "update"}
+	if needs.Delete {
+		verbs = append(verbs, "delete")
+	}
+	if needs.List || needs.Preflight {
+		// The quota check of the preflight lists the live objects.
+		verbs = append(verbs, "list")
+	}
+
+	seen := make(map[Permission]bool)
+	var perms []Permission
+	add := func(p Permission) {
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		mapping, err := client.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			continue
+		}
+		namespace := ""
+		if mapping.Scope.Name() == "namespace" {
+			namespace = obj.GetNamespace()
+			if namespace == "" {
+				namespace = namespaceFallback
+			}
+		}
+		for _, verb := range verbs {
+			add(Permission{
+				Namespace: namespace,
+				Group:     mapping.Resource.Group,
+				Resource:  mapping.Resource.Resource,
+				Verb:      verb,
+			})
+		}
+		// The wait reads the objects, which get already covers, and lists
+		// the events of those that do not become ready.
+		if needs.Wait && k8s.IsWaitable(obj.GetKind()) {
+			add(Permission{Namespace: namespace, Resource: "events", Verb: "list"})
+		}
+	}
+
+	if needs.Preflight {
+		for _, p := range preflightPermissions(objs, namespaceFallback) {
+			add(p)
+		}
+	}
+	if needs.SchemaCheck {
+		for _, path := range openAPIPaths {
+			add(Permission{Path: path, Verb: "get"})
+		}
+	}
+	return perms
+}
+
+// CheckPermissions asks the API server with SelfSubjectAccessReviews
+// whether the current user has perms. It returns a *PermissionError listing
+// the missing ones, or nil if all are granted.
+func CheckPermissions(ctx context.Context, client *k8s.Client, perms []Permission) error {
+	var missing []Permission
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{}
+		if p.Path != "" {
+			review.Spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{Path: p.Path, Verb: p.Verb}
+		} else {
+			review.Spec.ResourceAttributes = &authorizationv1.ResourceAttributes{
+				Namespace: p.Namespace,
+				Group:     p.Group,
+				Resource:  p.Resource,
+				Verb:      p.Verb,
+			}
+		}
+		resp, err := client.Clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("check permission to %s: %w", p, err)
+		}
+		if !resp.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &PermissionError{Missing: missing}
+	}
+	return nil
+}
+
+// PrintPermissions writes perms as a NAMESPACE/VERB/RESOURCE table to out.
+func PrintPermissions(out io.Writer, perms []Permission) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "NAMESPACE\tVERB\tRESOURCE\n"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, p := range perms {
+		namespace := p.Namespace
+		if namespace == "" {
+			namespace = "(cluster)"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", namespace, p.Verb, p.resourceName()); err != nil {
+			return fmt.Errorf("failed to write permission: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+	return nil
+}
+
+// RenderRoles returns a YAML manifest with a Role per namespace (and a
+// ClusterRole for cluster-scoped resources and non-resource URLs) named
+// name, granting exactly perms.
+func RenderRoles(name string, perms []Permission) ([]byte, error) {
+	// namespace -> group/resource/path -> verbs
+	grants := make(map[string]map[ruleKey][]string)
+	for _, p := range perms {
+		if grants[p.Namespace] == nil {
+			grants[p.Namespace] = make(map[ruleKey][]string)
+		}
+		key := ruleKey{p.Group, p.Resource, p.Path}
+		grants[p.Namespace][key] = append(grants[p.Namespace][key], p.Verb)
+	}
+
+	namespaces := make([]string, 0, len(grants))
+	for ns := range grants {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var docs []string
+	for _, ns := range namespaces {
+		rules := policyRules(grants[ns])
+
+		var obj interface{}
+		if ns == "" {
+			obj = rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Rules:      rules,
+			}
+		} else {
+			obj = rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Rules:      rules,
+			}
+		}
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("marshal role: %w", err)
+		}
+		// Drop the empty creationTimestamp the typed objects carry.
+		docs = append(docs, strings.Replace(string(data), "  creationTimestamp: null\n", "", 1))
+	}
+
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// PolicyRules returns the rules granting perms regardless of their
+// namespace, for a ClusterRole that is bound per namespace with RoleBindings.
+func PolicyRules(perms []Permission) []rbacv1.PolicyRule {
+	grants := make(map[ruleKey][]string)
+	for _, p := range perms {
+		key := ruleKey{p.Group, p.Resource, p.Path}
+		if !slices.Contains(grants[key], p.Verb) {
+			grants[key] = append(grants[key], p.Verb)
+		}
+	}
+	return policyRules(grants)
+}
+
+// ruleKey is what a policy rule grants verbs on: a resource or, if path is
+// set, a non-resource URL.
+type ruleKey struct {
+	group, resource, path string
+}
+
+func policyRules(grants map[ruleKey][]string) []rbacv1.PolicyRule {
+	keys := make([]ruleKey, 0, len(grants))
+	for key := range grants {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].resource < keys[j].resource
+	})
+
+	rules := make([]rbacv1.PolicyRule, 0, len(keys))
+	for _, key := range keys {
+		if key.path != "" {
+			rules = append(rules, rbacv1.PolicyRule{
+				NonResourceURLs: []string{key.path},
+				Verbs:           grants[key],
+			})
+			continue
+		}
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{key.group},
+			Resources: []string{key.resource},
+			Verbs:     grants[key],
+		})
+	}
+	return rules
+}
@@ -0,0 +1,203 @@
+// Package schedule runs backups of several namespaces on cron schedules in a
+// single long-running process, replacing crontab entries and the shell
+// wrappers around them.
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// CatchUpPolicy decides what happens to runs missed while the scheduler was
+// not running.
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip drops missed runs and waits for the next scheduled time.
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpOnce runs a schedule once at startup if it missed any runs.
+	CatchUpOnce CatchUpPolicy = "once"
+)
+
+// DefaultStateFile is where last-run state is kept unless the config or the
+// command line says otherwise.
+const DefaultStateFile = "kubectl-backup-schedule-state.json"
+
+// Config is the content of a schedules file.
+//
+//	stateFile: /var/lib/kubectl-backup/state.json
+//	schedules:
+//	  - name: nightly
+//	    cron: "30 2 * * *"
+//	    namespaces: [shop, billing]
+//	    destination: /backups/nightly
+//	    jitter: 5m
+//	    catchUp: once
+//	    retention:
+//	      keepDaily: 7
+//	      keepWeekly: 4
+//	  - name: hourly-config
+//	    cron: "@hourly"
+//	    namespaces: [shop]
+//	    selector: tier=config
+//	    destination: /backups/hourly
+//	    retention:
+//	      keepLast: 24
+type Config struct {
+	StateFile string     `json:"stateFile,omitempty"`
+	Schedules []Schedule `json:"schedules"`
+}
+
+// Schedule backs up Namespaces into Destination whenever Cron fires.
+type Schedule struct {
+	Name string `json:"name"`
+	// Cron is a standard five-field cron expression or a descriptor such as
+	// @daily or @every 6h. Times are interpreted in the local time zone
+	// unless the expression starts with CRON_TZ=<zone>.
+	Cron       string   `json:"cron"`
+	Namespaces []string `json:"namespaces"`
+	// Selector restricts the backups to objects with matching labels.
+	Selector string `json:"selector,omitempty"`
+	// Destination is the directory archives are written to. It defaults to
+	// the current working directory. Retention is applied per namespace
+	// within this directory, so schedules should not share one.
+	Destination string `json:"destination,omitempty"`
+	// Retention deletes old archives after every successful run.
+	Retention Retention `json:"retention,omitempty"`
+	// Jitter delays every run by a random duration up to this value, so
+	// that schedules firing at the same time do not hit the API server at
+	// once.
+	Jitter Duration `json:"jitter,omitempty"`
+	// CatchUp is skip or once (the default).
+	CatchUp CatchUpPolicy `json:"catchUp,omitempty"`
+	// BestEffort writes partial backups instead of failing when some
+	// resource types cannot be read.
+	BestEffort bool `json:"bestEffort,omitempty"`
+
+	schedule cron.Schedule
+}
+
+// Retention mirrors the keep rules of the prune command.
+type Retention struct {
+	KeepLast    int `json:"keepLast,omitempty"`
+	KeepDaily   int `json:"keepDaily,omitempty"`
+	KeepWeekly  int `json:"keepWeekly,omitempty"`
+	KeepMonthly int `json:"keepMonthly,omitempty"`
+}
+
+// Policy converts r to a backup retention policy.
+func (r Retention) Policy() backup.RetentionPolicy {
+	return backup.RetentionPolicy{
+		KeepLast:    r.KeepLast,
+		KeepDaily:   r.KeepDaily,
+		KeepWeekly:  r.KeepWeekly,
+		KeepMonthly: r.KeepMonthly,
+	}
+}
+
+// Duration is a time.Duration written as a string such as 90s or 5m.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON decodes a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as 5m: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON encodes d as a duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Next returns the first time the schedule fires after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t)
+}
+
+// LoadFile reads and validates a schedules file.
+func LoadFile(filename string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("read schedules file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a schedules file.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode schedules file: %w", err)
+	}
+
+	if len(cfg.Schedules) == 0 {
+		return nil, fmt.Errorf("no schedules defined")
+	}
+	seen := make(map[string]bool)
+	for i := range cfg.Schedules {
+		s := &cfg.Schedules[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("schedule %d: name is required", i+1)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("schedule %s: duplicate name", s.Name)
+		}
+		seen[s.Name] = true
+		if err := s.compile(); err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (s *Schedule) compile() error {
+	if s.Cron == "" {
+		return fmt.Errorf("cron is required")
+	}
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+	}
+	s.schedule = schedule
+
+	if len(s.Namespaces) == 0 {
+		return fmt.Errorf("at least one namespace is required")
+	}
+	if s.Selector != "" {
+		if _, err := labels.Parse(s.Selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", s.Selector, err)
+		}
+	}
+	if s.Jitter.Duration < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+
+	switch s.CatchUp {
+	case "":
+		s.CatchUp = CatchUpOnce
+	case CatchUpSkip, CatchUpOnce:
+	default:
+		return fmt.Errorf("invalid catchUp %q, must be %s or %s", s.CatchUp, CatchUpSkip, CatchUpOnce)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/morheus9/k8s-backup-cli/internal/backup"
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+)
+
+// Runner runs the schedules of a Config until its context is cancelled.
+type Runner struct {
+	config    *Config
+	client    *k8s.Client
+	statePath string
+	out       io.Writer
+
+	mu    sync.Mutex
+	state *State
+}
+
+// NewRunner returns a Runner that backs up with client, keeps its state in
+// statePath and logs to out.
+func NewRunner(config *Config, client *k8s.Client, statePath string, out io.Writer) *Runner {
+	return &Runner{
+		config:    config,
+		client:    client,
+		statePath: statePath,
+		out:       out,
+	}
+}
+
+// Run starts every schedule and blocks until ctx is cancelled and all
+// in-flight runs have finished. Runs of the same schedule never overlap: a
+// run that takes longer than the schedule interval causes the slots it
+// overran to be skipped.
+func (r *Runner) Run(ctx context.Context) error {
+	state, err := LoadState(r.statePath)
+	if err != nil {
+		return err
+	}
+	r.state = state
+
+	var wg sync.WaitGroup
+	for i := range r.config.Schedules {
+		s := &r.config.Schedules[i]
+		r.logf(s, "next run at %s", s.Next(time.Now()).Format(time.RFC3339))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.loop(ctx, s)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (r *Runner) loop(ctx context.Context, s *Schedule) {
+	r.catchUp(ctx, s)
+
+	next := s.Next(time.Now())
+	for {
+		delay := time.Until(next)
+		if s.Jitter.Duration > 0 {
+			delay += rand.N(s.Jitter.Duration)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		r.run(ctx, s, next)
+
+		following := s.Next(next)
+		skipped := 0
+		for now := time.Now(); following.Before(now); following = s.Next(following) {
+			skipped++
+		}
+		if skipped > 0 {
+			r.logf(s, "run took longer than the schedule interval, skipped %d run(s)", skipped)
+		}
+		next = following
+	}
+}
+
+// catchUp handles runs missed while the scheduler was not running, according
+// to the schedule's catch-up policy.
+func (r *Runner) catchUp(ctx context.Context, s *Schedule) {
+	r.mu.Lock()
+	last, ok := r.state.Schedules[s.Name]
+	r.mu.Unlock()
+	if !ok || last.LastRun.IsZero() {
+		return
+	}
+
+	missed := s.Next(last.LastRun)
+	if !missed.Before(time.Now()) {
+		return
+	}
+
+	switch s.CatchUp {
+	case CatchUpSkip:
+		r.logf(s, "missed run at %s, skipping it", missed.Format(time.RFC3339))
+	case CatchUpOnce:
+		r.logf(s, "missed run at %s, running now", missed.Format(time.RFC3339))
+		r.run(ctx, s, time.Now())
+	}
+}
+
+// run backs up every namespace of s and applies its retention, then records
+// the outcome in the state file.
+func (r *Runner) run(ctx context.Context, s *Schedule, scheduled time.Time) {
+	if s.Destination != "" {
+		if err := os.MkdirAll(s.Destination, 0o750); err != nil {
+			r.logf(s, "creating destination failed: %v", err)
+			r.record(s, scheduled, fmt.Errorf("create destination: %w", err))
+			return
+		}
+	}
+
+	var errs []error
+	for _, ns := range s.Namespaces {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ns, ctx.Err()))
+			break
+		}
+
+		result, err := backup.BackupNamespace(ctx, r.client, ns, backup.BackupOptions{
+			BestEffort: s.BestEffort,
+			OutputDir:  s.Destination,
+			Selector:   s.Selector,
+		})
+		if err != nil {
+			r.logf(s, "backup of %s failed: %v", ns, err)
+			errs = append(errs, fmt.Errorf("%s: %w", ns, err))
+			continue
+		}
+		if result.Partial() {
+			r.logf(s, "partial backup of %s created at %s", ns, result.Path)
+			for _, f := range result.Failures {
+				r.logf(s, "  %s: %s", f.Kind, f.Error)
+			}
+		} else {
+			r.logf(s, "backup of %s created at %s", ns, result.Path)
+		}
+
+		policy := s.Retention.Policy()
+		if policy.IsEmpty() {
+			continue
+		}
+		dir := s.Destination
+		if dir == "" {
+			dir = "."
+		}
+		decisions, err := backup.PruneArchives(dir, ns, policy, false)
+		if err != nil {
+			r.logf(s, "pruning backups of %s failed: %v", ns, err)
+			errs = append(errs, fmt.Errorf("%s: prune: %w", ns, err))
+			continue
+		}
+		deleted := 0
+		for _, d := range decisions {
+			if !d.Keep {
+				deleted++
+			}
+		}
+		if deleted > 0 {
+			r.logf(s, "deleted %d old backups of %s", deleted, ns)
+		}
+	}
+
+	r.record(s, scheduled, errors.Join(errs...))
+}
+
+func (r *Runner) record(s *Schedule, scheduled time.Time, runErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.state.Schedules[s.Name]
+	if !ok {
+		st = &RunState{}
+		r.state.Schedules[s.Name] = st
+	}
+	st.LastRun = scheduled
+	st.LastError = ""
+	if runErr != nil {
+		st.LastError = runErr.Error()
+	} else {
+		st.LastSuccess = scheduled
+	}
+
+	if err := r.state.Save(r.statePath); err != nil {
+		r.logf(s, "saving state failed: %v", err)
+	}
+}
+
+func (r *Runner) logf(s *Schedule, format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(r.out, "%s [%s] %s\n", time.Now().Format(time.RFC3339), s.Name, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is the last-run state of all schedules, persisted between restarts
+// so that missed runs can be detected.
+type State struct {
+	Schedules map[string]*RunState `json:"schedules"`
+}
+
+// RunState describes the most recent run of a schedule.
+type RunState struct {
+	// LastRun is the scheduled time of the most recent run.
+	LastRun time.Time `json:"lastRun"`
+	// LastSuccess is the scheduled time of the most recent successful run.
+	LastSuccess time.Time `json:"lastSuccess"`
+	// LastError is the error of the most recent run, if it failed.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// LoadState reads the state file at path. A missing file yields an empty
+// state.
+func LoadState(path string) (*State, error) {
+	state := &State{Schedules: make(map[string]*RunState)}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("decode state file %s: %w", path, err)
+	}
+	if state.Schedules == nil {
+		state.Schedules = make(map[string]*RunState)
+	}
+	return state, nil
+}
+
+// Save writes the state to path. The file is replaced atomically so that a
+// crash never leaves a truncated state behind.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create state file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is one step of a field path: a map key, a list index or every
+// element of a list.
+type segment struct {
+	key   string
+	index int
+	all   bool
+	isIdx bool
+}
+
+// fieldPath is a parsed field path such as
+// spec.template.spec.containers[*].image or
+// metadata.annotations["kubernetes.io/ingress.class"].
+type fieldPath []segment
+
+// parsePath parses a dotted field path. Keys containing dots are written in
+// brackets with double quotes; [*] selects every element of a list and [n]
+// a single element.
+func parsePath(s string) (fieldPath, error) {
+	var path fieldPath
+	rest := s
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated [", s)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				path = append(path, segment{all: true, isIdx: true})
+			case strings.HasPrefix(inner, `"`) && strings.HasSuffix(inner, `"`) && len(inner) >= 2:
+				path = append(path, segment{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index [%s]", s, inner)
+				}
+				path = append(path, segment{index: idx, isIdx: true})
+			}
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			if len(path) == 0 {
+				return nil, fmt.Errorf("invalid path %q: leading dot", s)
+			}
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid path %q: empty key", s)
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			path = append(path, segment{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid path %q: empty", s)
+	}
+	return path, nil
+}
+
+// set assigns value at path inside obj, creating missing maps along the way.
+// List elements are never created; paths through missing lists are ignored.
+// It reports whether anything was changed.
+func (p fieldPath) set(obj map[string]interface{}, value interface{}) bool {
+	return p.visit(obj, true, func(parent map[string]interface{}, key string) bool {
+		parent[key] = deepCopyValue(value)
+		return true
+	})
+}
+
+// replace substitutes from with to in every string value found at path.
+// It reports whether anything was changed.
+func (p fieldPath) replace(obj map[string]interface{}, from, to string) bool {
+	return p.visit(obj, false, func(parent map[string]interface{}, key string) bool {
+		current, ok := parent[key].(string)
+		if !ok || !strings.Contains(current, from) {
+			return false
+		}
+		parent[key] = strings.ReplaceAll(current, from, to)
+		return true
+	})
+}
+
+// visit walks the path and calls fn for every map entry addressed by the
+// final key segment.
+func (p fieldPath) visit(node interface{}, create bool, fn func(parent map[string]interface{}, key string) bool) bool {
+	seg := p[0]
+	last := len(p) == 1
+
+	if seg.isIdx {
+		list, ok := node.([]interface{})
+		if !ok || last {
+			// Setting whole list elements is not supported; use a JSON patch instead.
+			return false
+		}
+		changed := false
+		for i, item := range list {
+			if seg.all || i == seg.index {
+				if p[1:].visit(item, create, fn) {
+					changed = true
+				}
+			}
+		}
+		return changed
+	}
+
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if last {
+		return fn(m, seg.key)
+	}
+
+	child, exists := m[seg.key]
+	if !exists || child == nil {
+		if !create || p[1].isIdx {
+			return false
+		}
+		child = make(map[string]interface{})
+		m[seg.key] = child
+	}
+	return p[1:].visit(child, create, fn)
+}
+
+// deepCopyValue copies JSON-like values so one rule value is never shared
+// between objects.
+func deepCopyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = deepCopyValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = deepCopyValue(val)
+		}
+		return out
+	}
+	return v
+}
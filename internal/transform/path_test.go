@@ -0,0 +1,211 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    fieldPath
+		wantErr bool
+	}{
+		{path: "metadata.name", want: fieldPath{{key: "metadata"}, {key: "name"}}},
+		{
+			path: "spec.template.spec.containers[*].image",
+			want: fieldPath{{key: "spec"}, {key: "template"}, {key: "spec"}, {key: "containers"}, {all: true, isIdx: true}, {key: "image"}},
+		},
+		{
+			path: "spec.containers[1].image",
+			want: fieldPath{{key: "spec"}, {key: "containers"}, {index: 1, isIdx: true}, {key: "image"}},
+		},
+		{
+			path: `metadata.annotations["kubernetes.io/ingress.class"]`,
+			want: fieldPath{{key: "metadata"}, {key: "annotations"}, {key: "kubernetes.io/ingress.class"}},
+		},
+		{path: `["a.b"].c`, want: fieldPath{{key: "a.b"}, {key: "c"}}},
+		{path: "", wantErr: true},
+		{path: ".metadata", wantErr: true},
+		{path: "metadata.", wantErr: true},
+		{path: "metadata..name", wantErr: true},
+		{path: "metadata.[0]", wantErr: true},
+		{path: "spec.containers[0", wantErr: true},
+		{path: "spec.containers[-1]", wantErr: true},
+		{path: "spec.containers[x]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parsePath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePath(%q) = %v, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func pod() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "registry.old/web:1"},
+				map[string]interface{}{"name": "proxy", "image": "registry.old/proxy:1"},
+			},
+		},
+	}
+}
+
+func TestFieldPathSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		value   interface{}
+		want    map[string]interface{}
+		changed bool
+	}{
+		{
+			name:  "creates missing maps",
+			path:  `metadata.annotations["example.com/owner"]`,
+			value: "team-a",
+			want: func() map[string]interface{} {
+				obj := pod()
+				obj["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{"example.com/owner": "team-a"}
+				return obj
+			}(),
+			changed: true,
+		},
+		{
+			name:  "every list element",
+			path:  "spec.containers[*].imagePullPolicy",
+			value: "Always",
+			want: func() map[string]interface{} {
+				obj := pod()
+				for _, c := range obj["spec"].(map[string]interface{})["containers"].([]interface{}) {
+					c.(map[string]interface{})["imagePullPolicy"] = "Always"
+				}
+				return obj
+			}(),
+			changed: true,
+		},
+		{
+			name:  "one list element",
+			path:  "spec.containers[1].image",
+			value: "proxy:2",
+			want: func() map[string]interface{} {
+				obj := pod()
+				obj["spec"].(map[string]interface{})["containers"].([]interface{})[1].(map[string]interface{})["image"] = "proxy:2"
+				return obj
+			}(),
+			changed: true,
+		},
+		{name: "missing list is not created", path: "spec.volumes[*].name", value: "data", want: pod()},
+		{name: "whole list elements are not replaced", path: "spec.containers[0]", value: "web", want: pod()},
+		{name: "index out of range", path: "spec.containers[5].image", value: "web:2", want: pod()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := parsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := pod()
+			if changed := path.set(obj, tt.value); changed != tt.changed {
+				t.Errorf("set changed = %t, want %t", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(obj, tt.want) {
+				t.Errorf("set = %v, want %v", obj, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldPathSetCopiesValue(t *testing.T) {
+	path, err := parsePath("spec.containers[*].env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := pod()
+	path.set(obj, []interface{}{map[string]interface{}{"name": "MODE", "value": "restore"}})
+
+	containers := obj["spec"].(map[string]interface{})["containers"].([]interface{})
+	env := containers[0].(map[string]interface{})["env"].([]interface{})
+	env[0].(map[string]interface{})["value"] = "changed"
+	other := containers[1].(map[string]interface{})["env"].([]interface{})
+	if got := other[0].(map[string]interface{})["value"]; got != "restore" {
+		t.Errorf("second container env value = %v, want the value not to be shared", got)
+	}
+}
+
+func TestFieldPathReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		from    string
+		to      string
+		images  []string
+		changed bool
+	}{
+		{
+			name:    "every list element",
+			path:    "spec.containers[*].image",
+			from:    "registry.old",
+			to:      "registry.new",
+			images:  []string{"registry.new/web:1", "registry.new/proxy:1"},
+			changed: true,
+		},
+		{
+			name:    "one list element",
+			path:    "spec.containers[0].image",
+			from:    "registry.old",
+			to:      "registry.new",
+			images:  []string{"registry.new/web:1", "registry.old/proxy:1"},
+			changed: true,
+		},
+		{
+			name:   "no match",
+			path:   "spec.containers[*].image",
+			from:   "docker.io",
+			to:     "registry.new",
+			images: []string{"registry.old/web:1", "registry.old/proxy:1"},
+		},
+		{
+			name:   "missing field is not created",
+			path:   "spec.initContainers[*].image",
+			from:   "registry.old",
+			to:     "registry.new",
+			images: []string{"registry.old/web:1", "registry.old/proxy:1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := parsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := pod()
+			if changed := path.replace(obj, tt.from, tt.to); changed != tt.changed {
+				t.Errorf("replace changed = %t, want %t", changed, tt.changed)
+			}
+			var images []string
+			for _, c := range obj["spec"].(map[string]interface{})["containers"].([]interface{}) {
+				images = append(images, c.(map[string]interface{})["image"].(string))
+			}
+			if !reflect.DeepEqual(images, tt.images) {
+				t.Errorf("images = %v, want %v", images, tt.images)
+			}
+			if _, ok := obj["spec"].(map[string]interface{})["initContainers"]; ok {
+				t.Error("replace created spec.initContainers")
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Package transform rewrites objects during a restore according to a
+// declarative transform file, e.g. to point images at another registry or
+// change StorageClass names when restoring a production backup into staging.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/morheus9/k8s-backup-cli/internal/k8s"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the content of a transform file.
+//
+//	rules:
+//	  - name: staging-registry
+//	    match:
+//	      kinds: [Deployment, StatefulSet]
+//	    replace:
+//	      - path: spec.template.spec.containers[*].image
+//	        from: registry.prod.example.com/
+//	        to: registry.staging.example.com/
+//	  - name: single-replica
+//	    match:
+//	      kinds: [Deployment]
+//	      selector: tier=frontend
+//	    set:
+//	      - path: spec.replicas
+//	        value: 1
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule applies its actions to every object selected by Match. Actions run in
+// the order jsonPatch, mergePatch, strategicMerge, set, replace.
+type Rule struct {
+	Name  string `json:"name,omitempty"`
+	Match Match  `json:"match,omitempty"`
+
+	// JSONPatch is an RFC 6902 JSON patch.
+	JSONPatch json.RawMessage `json:"jsonPatch,omitempty"`
+	// MergePatch is an RFC 7386 JSON merge patch.
+	MergePatch json.RawMessage `json:"mergePatch,omitempty"`
+	// StrategicMerge is a strategic merge patch. Kinds unknown to the
+	// built-in scheme fall back to a JSON merge patch.
+	StrategicMerge json.RawMessage `json:"strategicMerge,omitempty"`
+	// Set assigns values to fields.
+	Set []FieldSet `json:"set,omitempty"`
+	// Replace substitutes substrings in string fields.
+	Replace []FieldReplace `json:"replace,omitempty"`
+
+	selector labels.Selector
+	patch    jsonpatch.Patch
+	sets     []compiledSet
+	replaces []compiledReplace
+}
+
+// Match selects objects by kind, name glob, namespace and labels. Empty
+// fields match everything.
+type Match struct {
+	Kinds      []string `json:"kinds,omitempty"`
+	Names      []string `json:"names,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Selector   string   `json:"selector,omitempty"`
+}
+
+// FieldSet assigns Value to the field at Path.
+type FieldSet struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// FieldReplace replaces From with To in the string fields at Path.
+type FieldReplace struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type compiledSet struct {
+	path  fieldPath
+	value interface{}
+}
+
+type compiledReplace struct {
+	path     fieldPath
+	from, to string
+}
+
+// LoadFile reads and validates a transform file.
+func LoadFile(filename string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("read transform file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a transform file.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode transform file: %w", err)
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (r *Rule) compile() error {
+	if len(r.JSONPatch) == 0 && len(r.MergePatch) == 0 && len(r.StrategicMerge) == 0 &&
+		len(r.Set) == 0 && len(r.Replace) == 0 {
+		return fmt.Errorf("no actions defined")
+	}
+
+	for _, pattern := range r.Match.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid name glob %q: %w", pattern, err)
+		}
+	}
+
+	if r.Match.Selector != "" {
+		selector, err := labels.Parse(r.Match.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid selector %q: %w", r.Match.Selector, err)
+		}
+		r.selector = selector
+	}
+
+	if len(r.JSONPatch) > 0 {
+		patch, err := jsonpatch.DecodePatch(r.JSONPatch)
+		if err != nil {
+			return fmt.Errorf("invalid jsonPatch: %w", err)
+		}
+		r.patch = patch
+	}
+
+	for _, set := range r.Set {
+		p, err := parsePath(set.Path)
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		if err := utiljson.Unmarshal(set.Value, &value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", set.Path, err)
+		}
+		r.sets = append(r.sets, compiledSet{path: p, value: value})
+	}
+
+	for _, rep := range r.Replace {
+		p, err := parsePath(rep.Path)
+		if err != nil {
+			return err
+		}
+		if rep.From == "" {
+			return fmt.Errorf("replace at %s: from must not be empty", rep.Path)
+		}
+		r.replaces = append(r.replaces, compiledReplace{path: p, from: rep.From, to: rep.To})
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule applies to obj.
+func (r *Rule) Matches(obj *unstructured.Unstructured) bool {
+	if len(r.Match.Kinds) > 0 && !matchesKind(r.Match.Kinds, obj.GetKind()) {
+		return false
+	}
+	if len(r.Match.Namespaces) > 0 && !contains(r.Match.Namespaces, obj.GetNamespace()) {
+		return false
+	}
+	if len(r.Match.Names) > 0 {
+		found := false
+		for _, pattern := range r.Match.Names {
+			if ok, _ := path.Match(pattern, obj.GetName()); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.selector != nil && !r.selector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	return true
+}
+
+// Apply runs every matching rule against obj, modifying it in place. It
+// returns the names of the rules that changed the object.
+func (c *Config) Apply(obj *unstructured.Unstructured) ([]string, error) {
+	var applied []string
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if !rule.Matches(obj) {
+			continue
+		}
+		changed, err := rule.apply(obj)
+		if err != nil {
+			return applied, fmt.Errorf("rule %s on %s/%s: %w", rule.Name, obj.GetKind(), obj.GetName(), err)
+		}
+		if changed {
+			applied = append(applied, rule.Name)
+		}
+	}
+	return applied, nil
+}
+
+func (r *Rule) apply(obj *unstructured.Unstructured) (bool, error) {
+	original, err := obj.MarshalJSON()
+	if err != nil {
+		return false, fmt.Errorf("encode object: %w", err)
+	}
+	doc := original
+
+	if r.patch != nil {
+		if doc, err = r.patch.Apply(doc); err != nil {
+			return false, fmt.Errorf("apply jsonPatch: %w", err)
+		}
+	}
+	if len(r.MergePatch) > 0 {
+		if doc, err = jsonpatch.MergePatch(doc, r.MergePatch); err != nil {
+			return false, fmt.Errorf("apply mergePatch: %w", err)
+		}
+	}
+	if len(r.StrategicMerge) > 0 {
+		if doc, err = strategicMerge(obj, doc, r.StrategicMerge); err != nil {
+			return false, fmt.Errorf("apply strategicMerge: %w", err)
+		}
+	}
+
+	if string(doc) != string(original) {
+		if err := obj.UnmarshalJSON(doc); err != nil {
+			return false, fmt.Errorf("decode patched object: %w", err)
+		}
+	}
+
+	for _, set := range r.sets {
+		set.path.set(obj.Object, set.value)
+	}
+	for _, rep := range r.replaces {
+		rep.path.replace(obj.Object, rep.from, rep.to)
+	}
+
+	result, err := obj.MarshalJSON()
+	if err != nil {
+		return false, fmt.Errorf("encode object: %w", err)
+	}
+	return string(result) != string(original), nil
+}
+
+// strategicMerge applies patch to doc using the patch strategy of the
+// built-in type for obj's kind, or a JSON merge patch for unknown kinds.
+func strategicMerge(obj *unstructured.Unstructured, doc, patch []byte) ([]byte, error) {
+	typed, err := scheme.Scheme.New(obj.GroupVersionKind())
+	if err != nil {
+		return jsonpatch.MergePatch(doc, patch)
+	}
+	return strategicpatch.StrategicMergePatch(doc, patch, typed)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if strings.EqualFold(k8s.NormalizeKind(k), kind) {
+			return true
+		}
+	}
+	return false
+}